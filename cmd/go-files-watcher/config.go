@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+)
+
+// config is the content of the JSON configuration file. Fields left out
+// keep the daemon defaults.
+type config struct {
+	Rule      string   `json:"rule"`
+	BasePath  string   `json:"base_path"`
+	BasePaths []string `json:"base_paths"`
+	Extension string   `json:"extension"`
+	Excluded  []string `json:"excluded"`
+	Frequency int32    `json:"frequency"`
+	Command   string   `json:"command"`
+	Restart   bool     `json:"restart"`
+
+	Readiness *probeConfig `json:"readiness"`
+
+	// ContentHash confirms modifications by hashing the content of files
+	// up to MaxHashSize bytes (16MiB by default).
+	ContentHash bool  `json:"content_hash"`
+	MaxHashSize int64 `json:"max_hash_size"`
+	// Outputs are globs of the files written by the command.
+	Outputs []string `json:"outputs"`
+	// EditorPatterns replace the default globs of editor temporary files.
+	EditorPatterns []string `json:"editor_patterns"`
+	// Attributes tracks the mode and owner of the files, Xattrs also
+	// their extended attributes.
+	Attributes bool `json:"attributes"`
+	Xattrs     bool `json:"xattrs"`
+	// Directories tracks the directories below the base paths too.
+	Directories bool `json:"directories"`
+
+	WriteStability *stabilityConfig `json:"write_stability"`
+
+	// FollowSymlinks walks through symlinked directories, with targets
+	// within the base path or SymlinkRoots, if given.
+	FollowSymlinks bool     `json:"follow_symlinks"`
+	SymlinkRoots   []string `json:"symlink_roots"`
+	// ModuleRoots watches the local modules of go.work and go.mod too.
+	ModuleRoots bool `json:"module_roots"`
+	// Embeds watches the files embedded by go:embed directives too.
+	Embeds bool `json:"embeds"`
+
+	Build *buildConfig `json:"build"`
+}
+
+// buildConfig is the build the changes of the Go files must be part of,
+// empty GOOS and GOARCH default to those of the go command.
+type buildConfig struct {
+	GOOS   string   `json:"goos"`
+	GOARCH string   `json:"goarch"`
+	Tags   []string `json:"tags"`
+}
+
+// stabilityConfig is the wait for changed files to be written,
+// durations are in the time.ParseDuration format, eg 500ms.
+type stabilityConfig struct {
+	Stable  string `json:"stable"`
+	MaxWait string `json:"max_wait"`
+}
+
+func (sc *stabilityConfig) option() (daemon.Option, error) {
+	stable, err := time.ParseDuration(sc.Stable)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid write stability duration")
+	}
+	var maxWait time.Duration
+	if sc.MaxWait != "" {
+		if maxWait, err = time.ParseDuration(sc.MaxWait); err != nil {
+			return nil, errors.Wrap(err, "invalid write stability max wait")
+		}
+	}
+	return daemon.WithWriteStability(stable, maxWait), nil
+}
+
+// probeConfig is the readiness check of the restart mode,
+// durations are in the time.ParseDuration format, eg 500ms.
+type probeConfig struct {
+	TCP      string `json:"tcp"`
+	HTTP     string `json:"http"`
+	Command  string `json:"command"`
+	Timeout  string `json:"timeout"`
+	Interval string `json:"interval"`
+}
+
+func (pc *probeConfig) probe() (*daemon.Probe, error) {
+	p := &daemon.Probe{TCP: pc.TCP, HTTP: pc.HTTP, Command: pc.Command}
+	var err error
+	if pc.Timeout != "" {
+		if p.Timeout, err = time.ParseDuration(pc.Timeout); err != nil {
+			return nil, errors.Wrap(err, "invalid readiness timeout")
+		}
+	}
+	if pc.Interval != "" {
+		if p.Interval, err = time.ParseDuration(pc.Interval); err != nil {
+			return nil, errors.Wrap(err, "invalid readiness interval")
+		}
+	}
+	return p, nil
+}
+
+// defaultOptions is the configuration used when no configuration file
+// is provided.
+func defaultOptions() []daemon.Option {
+	return []daemon.Option{
+		daemon.WithCommand("echo \"Hello world\""),
+		//daemon.WithCommand("go build -o go-files-watcher cmd/go-files-watcher/main.go"))
+		daemon.WithExcluded([]string{"internal/daemon/fixtures/*"}),
+		daemon.WithFrequency(5),
+	}
+}
+
+// loadOptions reads the configuration file into daemon options.
+func loadOptions(path string) ([]daemon.Option, error) {
+	if path == "" {
+		return defaultOptions(), nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read configuration")
+	}
+	cfg := config{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse configuration %s", path)
+	}
+
+	ops := []daemon.Option{}
+	if cfg.Rule != "" {
+		ops = append(ops, daemon.WithRule(cfg.Rule))
+	}
+	if cfg.BasePath != "" {
+		ops = append(ops, daemon.WithBasePath(cfg.BasePath))
+	}
+	if len(cfg.BasePaths) > 0 {
+		ops = append(ops, daemon.WithBasePaths(cfg.BasePaths))
+	}
+	if cfg.Extension != "" {
+		ops = append(ops, daemon.WithExtension(cfg.Extension))
+	}
+	if cfg.Excluded != nil {
+		ops = append(ops, daemon.WithExcluded(cfg.Excluded))
+	}
+	if cfg.Frequency > 0 {
+		ops = append(ops, daemon.WithFrequency(cfg.Frequency))
+	}
+	if cfg.Command != "" {
+		ops = append(ops, daemon.WithCommand(cfg.Command))
+	}
+	if cfg.Restart {
+		ops = append(ops, daemon.WithRestart(true))
+	}
+	if cfg.Readiness != nil {
+		p, err := cfg.Readiness.probe()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, daemon.WithReadiness(p))
+	}
+	wops, err := watchOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append(ops, wops...), nil
+}
+
+// watchOptions provides the options of the change detection.
+func watchOptions(cfg config) ([]daemon.Option, error) {
+	ops := []daemon.Option{}
+	if cfg.ContentHash {
+		max := cfg.MaxHashSize
+		if max <= 0 {
+			max = daemon.DefaultMaxHashSize
+		}
+		ops = append(ops, daemon.WithContentHash(max))
+	}
+	if cfg.Outputs != nil {
+		if err := checkGlobs(cfg.Outputs); err != nil {
+			return nil, err
+		}
+		ops = append(ops, daemon.WithOutputs(cfg.Outputs))
+	}
+	if cfg.EditorPatterns != nil {
+		if err := checkGlobs(cfg.EditorPatterns); err != nil {
+			return nil, err
+		}
+		ops = append(ops, daemon.WithEditorPatterns(cfg.EditorPatterns))
+	}
+	if cfg.Attributes {
+		ops = append(ops, daemon.WithAttributes(true, cfg.Xattrs))
+	}
+	if cfg.Directories {
+		ops = append(ops, daemon.WithDirectories(true))
+	}
+	if cfg.ModuleRoots {
+		ops = append(ops, daemon.WithModuleRoots(true))
+	}
+	if cfg.Embeds {
+		ops = append(ops, daemon.WithEmbeds(true))
+	}
+	if cfg.Build != nil {
+		ops = append(ops, daemon.WithBuildConstraints(cfg.Build.GOOS, cfg.Build.GOARCH, cfg.Build.Tags))
+	}
+	if cfg.FollowSymlinks {
+		ops = append(ops, daemon.WithSymlinks(true, cfg.SymlinkRoots))
+	}
+	if cfg.WriteStability != nil {
+		o, err := cfg.WriteStability.option()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, o)
+	}
+	return ops, nil
+}
+
+func checkGlobs(globs []string) error {
+	for _, g := range globs {
+		if _, err := filepath.Match(g, ""); err != nil {
+			return errors.Wrapf(err, "invalid glob %q", g)
+		}
+	}
+	return nil
+}
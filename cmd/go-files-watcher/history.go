@@ -0,0 +1,190 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/history"
+)
+
+// historyMain runs the history subcommand, providing the exit code.
+func historyMain(args []string) int {
+	if err := runHistory(args, os.Stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 2
+	}
+	return 0
+}
+
+// runHistory implements the history subcommand:
+//
+//	history [list] [--failed] [--rule name] [--file path] [--since 24h] [--limit n]
+//	history show <id>
+//	history stats [--failed] [--rule name] [--file path] [--since 24h]
+func runHistory(args []string, out io.Writer) error {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	path := fs.String("store", "", "location of the history (the user's state directory by default)")
+	failed := fs.Bool("failed", false, "only failed runs")
+	rule := fs.String("rule", "", "only runs of the rule")
+	file := fs.String("file", "", "only runs triggered by a change of a path containing the value")
+	since := fs.Duration("since", 0, "only runs started within the duration, eg 24h")
+	limit := fs.Int("limit", 20, "number of the most recent runs listed, 0 for all")
+	if action == "stats" {
+		*since = 24 * time.Hour
+		fs.Lookup("since").DefValue = "24h"
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	records, err := loadHistory(*path)
+	if err != nil {
+		return err
+	}
+	filter := history.Filter{FailedOnly: *failed, Rule: *rule, File: *file}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	switch action {
+	case "list":
+		printRecords(out, lastRecords(history.Select(records, filter), *limit))
+	case "show":
+		return showRecord(out, records, fs.Args())
+	case "stats":
+		printStats(out, history.Summarize(history.Select(records, filter)), *since)
+	default:
+		return fmt.Errorf("unknown history command %q, use list, show or stats", action)
+	}
+	return nil
+}
+
+func loadHistory(path string) ([]history.Record, error) {
+	if path == "" {
+		p, err := history.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return store.Load()
+}
+
+func lastRecords(records []history.Record, limit int) []history.Record {
+	if limit > 0 && len(records) > limit {
+		return records[len(records)-limit:]
+	}
+	return records
+}
+
+func printRecords(out io.Writer, records []history.Record) {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tDURATION\tRESULT\tRULE\tTRIGGERS")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+			r.ID,
+			r.Started.Local().Format("2006-01-02 15:04:05"),
+			r.Duration().Round(time.Millisecond),
+			result(r),
+			r.Rule,
+			triggers(r),
+		)
+	}
+	tw.Flush()
+}
+
+func showRecord(out io.Writer, records []history.Record, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: history show <id>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid run id %q", args[0])
+	}
+	for _, r := range records {
+		if r.ID != id {
+			continue
+		}
+		fmt.Fprintf(out, "Run:       %d\n", r.ID)
+		fmt.Fprintf(out, "Rule:      %s\n", r.Rule)
+		fmt.Fprintf(out, "Command:   %s\n", strings.Join(r.Args, " "))
+		fmt.Fprintf(out, "Started:   %s\n", r.Started.Local().Format(time.RFC3339))
+		fmt.Fprintf(out, "Finished:  %s\n", r.Finished.Local().Format(time.RFC3339))
+		fmt.Fprintf(out, "Duration:  %s\n", r.Duration().Round(time.Millisecond))
+		fmt.Fprintf(out, "Result:    %s\n", result(r))
+		fmt.Fprintln(out, "Triggers:")
+		for _, t := range r.Triggers {
+			fmt.Fprintf(out, "  %s %s\n", t.Kind, t.Path)
+		}
+		fmt.Fprintf(out, "Output:\n%s\n", r.Output)
+		return nil
+	}
+	return fmt.Errorf("run %d not found", id)
+}
+
+func printStats(out io.Writer, st history.Stats, since time.Duration) {
+	period := "all time"
+	if since > 0 {
+		period = "last " + since.String()
+	}
+	fmt.Fprintf(out, "Period:           %s\n", period)
+	fmt.Fprintf(out, "Runs:             %d\n", st.Runs)
+	fmt.Fprintf(out, "Failed:           %d\n", st.Failed)
+	fmt.Fprintf(out, "Average duration: %s\n", st.AverageDuration.Round(time.Millisecond))
+}
+
+func result(r history.Record) string {
+	switch {
+	case r.State != "":
+		return r.State
+	case r.Failed():
+		return "exit " + strconv.Itoa(r.ExitCode)
+	default:
+		return "ok"
+	}
+}
+
+func triggers(r history.Record) string {
+	if len(r.Triggers) == 0 {
+		return "manual"
+	}
+	s := r.Triggers[0].Path
+	if len(r.Triggers) > 1 {
+		s += fmt.Sprintf(" (+%d more)", len(r.Triggers)-1)
+	}
+	return s
+}
+
+// historyRecorder provides the recorder of the runs or nil when the history
+// is disabled or cannot be opened.
+func historyRecorder(f *flags, output func() string) *history.Recorder {
+	if !f.history {
+		return nil
+	}
+	path, err := history.DefaultPath()
+	if err == nil {
+		var store *history.Store
+		if store, err = history.Open(path, history.WithMaxRecords(f.historyMax)); err == nil {
+			return history.NewRecorder(store, output, os.Stderr)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "run history disabled: %s\n", err)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/history"
+)
+
+func TestRunHistory(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.Open(path, history.WithMaxRecords(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-48 * time.Hour)
+	runs := []history.Record{
+		{Rule: "api", Triggers: []history.Trigger{{Path: "old.go", Kind: "modified"}}},
+		{Rule: "api", Triggers: []history.Trigger{{Path: "main.go", Kind: "modified"}}, ExitCode: 1, Output: "build failed"},
+		{Rule: "web", Started: start.Add(47 * time.Hour), Finished: start.Add(47*time.Hour + 2*time.Second)},
+	}
+	for i, r := range runs {
+		if r.Started.IsZero() {
+			r.Started, r.Finished = start.Add(time.Duration(i)*time.Minute), start.Add(time.Duration(i)*time.Minute)
+		}
+		if err := store.Append(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		action  string
+		args    []string
+		want    []string
+		notWant []string
+		wantErr bool
+	}{
+		{name: "list", want: []string{"2  ", "exit 1", "main.go", "3  ", "ok", "manual"}},
+		{name: "list failed", action: "list", args: []string{"--failed"}, want: []string{"main.go"}, notWant: []string{"web"}},
+		{name: "list limit", args: []string{"--limit", "1"}, want: []string{"web"}, notWant: []string{"main.go"}},
+		// the IDs survive the removal of the oldest record
+		{name: "show", action: "show", args: []string{"2"}, want: []string{"Run:       2", "modified main.go", "build failed"}},
+		{name: "show removed", action: "show", args: []string{"1"}, wantErr: true},
+		{name: "show invalid", action: "show", args: []string{"x"}, wantErr: true},
+		{name: "stats", action: "stats", want: []string{"last 24h0m0s", "Runs:             1", "Failed:           0"}},
+		{name: "stats all", action: "stats", args: []string{"--since", "0"}, want: []string{"Runs:             2", "Failed:           1"}},
+		{name: "unknown", action: "prune", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			out := &bytes.Buffer{}
+			args := append([]string{"--store", path}, tt.args...)
+			if tt.action != "" {
+				args = append([]string{tt.action}, args...)
+			}
+			err := runHistory(args, out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(out.String(), w) {
+					t.Errorf("runHistory() got %q, want it to contain %q", out, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(out.String(), w) {
+					t.Errorf("runHistory() got %q, want it not to contain %q", out, w)
+				}
+			}
+		})
+	}
+}
+
+func TestRunHistory_noStore(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "state")
+	out := &bytes.Buffer{}
+	if err := runHistory([]string{"--store", filepath.Join(dir, "history.jsonl")}, out); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("runHistory() created the directory of the history, error %v", err)
+	}
+}
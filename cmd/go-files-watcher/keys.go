@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/terminal"
+	"github.com/tamarakaufler/go-files-watcher/internal/tui"
+)
+
+const keysHelp = "keys: r re-run, p pause/resume, c clear, v verbose, l last log, q quit\n"
+
+// watchKeys switches the terminal to reading single key presses and
+// drives the daemon with them. Each key is also passed to the optional
+// extra handler. The returned function restores the terminal.
+func watchKeys(ctx context.Context, d *daemon.Daemon, in *os.File, out io.Writer, extra func(byte)) (func(), error) {
+	restore, err := terminal.MakeCbreak(in.Fd())
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprint(out, keysHelp)
+
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			b, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			handleKey(ctx, d, b, out)
+			if extra != nil {
+				extra(b)
+			}
+		}
+	}()
+
+	return func() {
+		//nolint:errcheck
+		restore()
+	}, nil
+}
+
+func handleKey(ctx context.Context, d *daemon.Daemon, key byte, out io.Writer) {
+	switch key {
+	case 'r':
+		fmt.Fprint(out, "re-running ...\n")
+		//nolint:errcheck
+		d.Trigger(ctx, nil)
+	case 'p':
+		if d.Status().Paused {
+			d.Resume()
+			fmt.Fprint(out, "watching resumed\n")
+		} else {
+			d.Pause()
+			fmt.Fprint(out, "watching paused\n")
+		}
+	case 'c':
+		fmt.Fprint(out, terminal.ClearScreen)
+	case 'v':
+		d.SetVerbose(!d.Verbose())
+		fmt.Fprintf(out, "verbose output: %t\n", d.Verbose())
+	case 'l':
+		fmt.Fprintf(out, "----- last run log -----\n%s------------------------\n", d.LastLog())
+	case 'q':
+		d.Quit()
+	case 'h', '?':
+		fmt.Fprint(out, keysHelp)
+	}
+}
+
+// dashboardKeys scrolls the output pane of the dashboard.
+func dashboardKeys(dash *tui.Dashboard) func(byte) {
+	return func(key byte) {
+		switch key {
+		case 'k':
+			dash.Scroll(1)
+		case 'j':
+			dash.Scroll(-1)
+		}
+	}
+}
+
+// screenSize provides the size of the terminal, 80x24 if unknown.
+func screenSize() (int, int) {
+	w, h, err := terminal.Size(os.Stdout.Fd())
+	if err != nil || w == 0 || h == 0 {
+		return 80, 24
+	}
+	return w, h
+}
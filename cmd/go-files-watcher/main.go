@@ -2,27 +2,241 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
-	"time"
 
+	"github.com/tamarakaufler/go-files-watcher/internal/api"
+	"github.com/tamarakaufler/go-files-watcher/internal/banner"
 	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/history"
+	"github.com/tamarakaufler/go-files-watcher/internal/runlog"
+	"github.com/tamarakaufler/go-files-watcher/internal/terminal"
+	"github.com/tamarakaufler/go-files-watcher/internal/tui"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
 )
 
+// number of recent events kept for clients resuming the event stream
+const streamBacklog = 1000
+
+type flags struct {
+	config         string
+	output         string
+	metricsAddr    string
+	apiAddr        string
+	livereloadAddr string
+	proxyAddr      string
+	proxyTarget    string
+	tui            bool
+	banners        bool
+	clear          bool
+	history        bool
+	historyMax     int
+	logDir         string
+	logMaxFiles    int
+	logMaxSize     int64
+	snapshot       bool
+	stateFile      string
+	catchUp        bool
+}
+
+func parseFlags() *flags {
+	f := &flags{}
+	flag.StringVar(&f.config, "config", "", "path to a JSON configuration file")
+	flag.StringVar(&f.output, "output", "text", "output format: text or json (NDJSON event stream on stdout)")
+	flag.StringVar(&f.metricsAddr, "metrics-addr", "",
+		"address to serve Prometheus metrics on, eg :9090 (disabled if empty)")
+	flag.StringVar(&f.apiAddr, "api-addr", "",
+		"address of the control API, eg localhost:8080 or unix:/tmp/watcher.sock")
+	flag.StringVar(&f.livereloadAddr, "livereload-addr", "", "address of the browser live-reload server, eg :35729")
+	flag.StringVar(&f.proxyAddr, "proxy-addr", "", "address of the development reverse proxy, eg :8000")
+	flag.StringVar(&f.proxyTarget, "proxy-target", "http://localhost:8080", "address of the application behind the proxy")
+	flag.BoolVar(&f.tui, "tui", false, "show a full screen dashboard (falls back to plain output when not in a terminal)")
+	flag.BoolVar(&f.banners, "banners", false, "print a banner before and a footer after each run")
+	flag.BoolVar(&f.clear, "clear", false, "clear the screen before each run")
+	flag.BoolVar(&f.history, "history", true, "record the runs to the history in the user's state directory")
+	flag.IntVar(&f.historyMax, "history-max-records", 1000, "number of runs kept in the history, 0 for all")
+	flag.StringVar(&f.logDir, "log-dir", "",
+		"directory capturing the output of each run to its own file (disabled if empty)")
+	flag.IntVar(&f.logMaxFiles, "log-max-files", 20, "number of run logs kept, 0 for all")
+	flag.BoolVar(&f.snapshot, "snapshot", true,
+		"persist the snapshot of the watched files to detect changes made while not running")
+	flag.StringVar(&f.stateFile, "state-file", "",
+		"location of the persisted snapshot (in the user's state directory by default)")
+	flag.BoolVar(&f.catchUp, "catch-up", false, "run the command on start if files changed while not running")
+	flag.Int64Var(&f.logMaxSize, "log-max-size", 100, "total size of the kept run logs in MiB, 0 for unlimited")
+	flag.Parse()
+
+	if f.output != "text" && f.output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", f.output)
+		os.Exit(2)
+	}
+	return f
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		os.Exit(historyMain(os.Args[2:]))
+	}
+	f := parseFlags()
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	d := daemon.New(
-		daemon.WithCommand("echo \"Hello world\""),
-		//daemon.WithCommand("go build -o go-files-watcher cmd/go-files-watcher/main.go"))
-		daemon.WithExcluded([]string{"internal/daemon/fixtures/*"}),
-		daemon.WithFrequency(5),
-	)
+	ops, err := loadOptions(f.config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ops = append(ops, daemon.WithReloadFunc(func() ([]daemon.Option, error) {
+		return loadOptions(f.config)
+	}))
+	ops = append(ops, serverOptions(f)...)
+
+	stream := api.NewStream(streamBacklog)
+	if f.apiAddr != "" {
+		ops = append(ops, daemon.WithEventHandler(stream))
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.Frequency)*time.Second)
+	ops = append(ops, runLogOptions(f)...)
+	ops = append(ops, snapshotOptions(f)...)
+
+	dash := newDashboard(f)
+	ops = append(ops, outputOptions(f, dash)...)
+
+	var d *daemon.Daemon
+	if rec := historyRecorder(f, func() string { return d.LastLog() }); rec != nil {
+		ops = append(ops, daemon.WithEventHandler(rec))
+	}
+
+	d = daemon.New(ops...)
+
+	if f.apiAddr != "" {
+		a := api.New(d)
+		a.Handle("/events", stream)
+		serveAPI(f.apiAddr, a)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+
+	if dash != nil {
+		dash.Start(d)
+		defer dash.Stop()
+	}
+	if restore := startKeys(ctx, f, d, dash); restore != nil {
+		defer restore()
+	}
+
 	d.Watch(ctx, sigCh)
 }
+
+// newDashboard provides the dashboard when requested and running in a terminal.
+func newDashboard(f *flags) *tui.Dashboard {
+	if !f.tui {
+		return nil
+	}
+	if f.output != "text" || !terminal.IsTerminal(os.Stdout.Fd()) || !terminal.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintln(os.Stderr, "not running in a terminal, dashboard disabled")
+		return nil
+	}
+	return tui.New(os.Stdout, screenSize)
+}
+
+// outputOptions sets up where the daemon output and events go.
+func outputOptions(f *flags, dash *tui.Dashboard) []daemon.Option {
+	if dash != nil {
+		// the dashboard shows the output of the command in its own pane
+		return []daemon.Option{
+			daemon.WithLogWriter(ioutil.Discard),
+			daemon.WithErrorWriter(ioutil.Discard),
+			daemon.WithEventHandler(dash),
+		}
+	}
+
+	ops := []daemon.Option{}
+	logOut := os.Stdout
+	if f.output == "json" {
+		// stdout is reserved for the event stream
+		logOut = os.Stderr
+		ops = append(ops,
+			daemon.WithLogWriter(logOut),
+			daemon.WithEventHandler(event.NewJSONWriter(os.Stdout)),
+		)
+	}
+	if f.banners || f.clear {
+		noColor := os.Getenv("NO_COLOR") != ""
+		b := banner.New(logOut,
+			banner.WithClearScreen(f.clear),
+			banner.WithColor(!noColor && terminal.IsTerminal(logOut.Fd())),
+		)
+		ops = append(ops, daemon.WithEventHandler(b))
+	}
+	return ops
+}
+
+// runLogOptions sets up capturing of the output of each run when requested.
+func runLogOptions(f *flags) []daemon.Option {
+	if f.logDir == "" {
+		return nil
+	}
+	dir, err := runlog.New(f.logDir,
+		runlog.WithMaxFiles(f.logMaxFiles),
+		runlog.WithMaxSize(f.logMaxSize<<20),
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run logs disabled: %s\n", err)
+		return nil
+	}
+	return []daemon.Option{daemon.WithOutputLog(dir)}
+}
+
+// snapshotOptions sets up persisting of the snapshot of the watched files.
+// By default the snapshot is kept in the state directory, in a file specific
+// to the working directory and configuration file.
+func snapshotOptions(f *flags) []daemon.Option {
+	if !f.snapshot {
+		return nil
+	}
+	path := f.stateFile
+	if path == "" {
+		dir, err := history.StateDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "snapshot not persisted: %s\n", err)
+			return nil
+		}
+		wd, _ := os.Getwd()
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s\x00%s", wd, f.config)
+		path = filepath.Join(dir, "snapshots", fmt.Sprintf("%x.json", h.Sum64()))
+	}
+	return []daemon.Option{daemon.WithStateFile(path), daemon.WithCatchUp(f.catchUp)}
+}
+
+// startKeys turns on the keyboard controls when running in a terminal.
+// It provides the function restoring the terminal, or nil.
+func startKeys(ctx context.Context, f *flags, d *daemon.Daemon, dash *tui.Dashboard) func() {
+	if f.output != "text" || !terminal.IsTerminal(os.Stdin.Fd()) {
+		return nil
+	}
+
+	out := io.Writer(os.Stdout)
+	var extra func(byte)
+	if dash != nil {
+		out = ioutil.Discard
+		extra = dashboardKeys(dash)
+	}
+
+	restore, err := watchKeys(ctx, d, os.Stdin, out, extra)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "keyboard controls disabled: %s\n", err)
+		return nil
+	}
+	return restore
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/api"
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/livereload"
+	"github.com/tamarakaufler/go-files-watcher/internal/metrics"
+	"github.com/tamarakaufler/go-files-watcher/internal/proxy"
+)
+
+// serverOptions starts the optional metrics, live-reload and proxy servers,
+// providing the options registering them for the daemon events.
+func serverOptions(f *flags) []daemon.Option {
+	ops := []daemon.Option{}
+
+	if f.metricsAddr != "" {
+		collector := metrics.New(daemon.BackendPoll)
+		ops = append(ops, daemon.WithEventHandler(collector))
+		serveMetrics(f.metricsAddr, collector)
+	}
+
+	if f.livereloadAddr != "" || f.proxyAddr != "" {
+		lr := livereload.New()
+		ops = append(ops, daemon.WithEventHandler(lr))
+		if f.livereloadAddr != "" {
+			serveLiveReload(f.livereloadAddr, lr)
+		}
+		if f.proxyAddr != "" {
+			serveProxy(f.proxyAddr, f.proxyTarget, lr)
+		}
+	}
+
+	return ops
+}
+
+func serveMetrics(addr string, h http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		fmt.Fprintf(os.Stderr, "metrics server stopped: %s\n", err)
+	}()
+}
+
+func serveLiveReload(addr string, h http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle(livereload.ScriptPath, h)
+	mux.Handle(livereload.SocketPath, h)
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		fmt.Fprintf(os.Stderr, "live-reload server stopped: %s\n", err)
+	}()
+}
+
+func serveProxy(addr, target string, lr http.Handler) {
+	u, err := url.Parse(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid proxy target: %s\n", err)
+		os.Exit(1)
+	}
+	p := proxy.New(u, proxy.WithLiveReload(lr))
+	go func() {
+		err := http.ListenAndServe(addr, p)
+		fmt.Fprintf(os.Stderr, "proxy stopped: %s\n", err)
+	}()
+}
+
+func serveAPI(addr string, h http.Handler) {
+	l, err := api.Listen(addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot start control API: %s\n", err)
+		os.Exit(1)
+	}
+	go func() {
+		err := http.Serve(l, h)
+		fmt.Fprintf(os.Stderr, "control API stopped: %s\n", err)
+	}()
+}
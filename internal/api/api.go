@@ -0,0 +1,129 @@
+// Package api provides the HTTP control API of the watcher daemon.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+)
+
+// Controller is the part of the daemon driven by the API.
+type Controller interface {
+	Status() daemon.Status
+	Trigger(ctx context.Context, changes []string) error
+	Pause()
+	Resume()
+	Reload(ctx context.Context) error
+}
+
+// TriggerRequest is the optional body of POST /trigger.
+type TriggerRequest struct {
+	Changes []string `json:"changes"`
+}
+
+// API serves the control endpoints.
+type API struct {
+	ctrl Controller
+	mux  *http.ServeMux
+}
+
+// New is a constructor providing a new API driving the controller.
+func New(ctrl Controller) *API {
+	a := &API{
+		ctrl: ctrl,
+		mux:  http.NewServeMux(),
+	}
+	a.mux.HandleFunc("/status", a.status)
+	a.mux.HandleFunc("/trigger", a.trigger)
+	a.mux.HandleFunc("/pause", a.pause)
+	a.mux.HandleFunc("/resume", a.resume)
+	a.mux.HandleFunc("/reload", a.reloadConfig)
+
+	return a
+}
+
+// Handle registers an additional handler, eg the event stream.
+func (a *API) Handle(pattern string, h http.Handler) {
+	a.mux.Handle(pattern, h)
+}
+
+// ServeHTTP dispatches the request to the endpoint handlers.
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *API) status(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, a.ctrl.Status())
+}
+
+func (a *API) trigger(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	req := TriggerRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	if err := a.ctrl.Trigger(r.Context(), req.Changes); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, a.ctrl.Status())
+}
+
+func (a *API) pause(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	a.ctrl.Pause()
+	writeJSON(w, http.StatusOK, a.ctrl.Status())
+}
+
+func (a *API) resume(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	a.ctrl.Resume()
+	writeJSON(w, http.StatusOK, a.ctrl.Status())
+}
+
+func (a *API) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := a.ctrl.Reload(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.ctrl.Status())
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	return false
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	//nolint:errcheck
+	json.NewEncoder(w).Encode(v)
+}
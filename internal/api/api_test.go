@@ -0,0 +1,155 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/api"
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+type fakeController struct {
+	status    daemon.Status
+	triggered []string
+	reloadErr error
+}
+
+func (f *fakeController) Status() daemon.Status { return f.status }
+
+func (f *fakeController) Trigger(ctx context.Context, changes []string) error {
+	f.triggered = changes
+	return nil
+}
+
+func (f *fakeController) Pause()  { f.status.Paused = true }
+func (f *fakeController) Resume() { f.status.Paused = false }
+
+func (f *fakeController) Reload(ctx context.Context) error { return f.reloadErr }
+
+func TestAPI(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		reloadErr  error
+		wantCode   int
+		wantPaused bool
+		wantChange []string
+	}{
+		{name: "status", method: http.MethodGet, path: "/status", wantCode: http.StatusOK},
+		{name: "status - wrong method", method: http.MethodPost, path: "/status", wantCode: http.StatusMethodNotAllowed},
+		{name: "trigger - no body", method: http.MethodPost, path: "/trigger", wantCode: http.StatusAccepted},
+		{
+			name:       "trigger - synthetic changes",
+			method:     http.MethodPost,
+			path:       "/trigger",
+			body:       `{"changes":["a.go","b/c.go"]}`,
+			wantCode:   http.StatusAccepted,
+			wantChange: []string{"a.go", "b/c.go"},
+		},
+		{name: "trigger - invalid body", method: http.MethodPost, path: "/trigger", body: `{`, wantCode: http.StatusBadRequest},
+		{name: "pause", method: http.MethodPost, path: "/pause", wantCode: http.StatusOK, wantPaused: true},
+		{name: "resume", method: http.MethodPost, path: "/resume", wantCode: http.StatusOK},
+		{name: "reload", method: http.MethodPost, path: "/reload", wantCode: http.StatusOK},
+		{
+			name:      "reload - failure",
+			method:    http.MethodPost,
+			path:      "/reload",
+			reloadErr: errors.New("bad config"),
+			wantCode:  http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := &fakeController{
+				status:    daemon.Status{BasePath: ".", FilesWatched: 3},
+				reloadErr: tt.reloadErr,
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			api.New(ctrl).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("%s %s code = %d, want %d", tt.method, tt.path, rec.Code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(ctrl.triggered, tt.wantChange) {
+				t.Errorf("%s %s triggered = %v, want %v", tt.method, tt.path, ctrl.triggered, tt.wantChange)
+			}
+			if rec.Code >= 300 {
+				return
+			}
+			st := daemon.Status{}
+			if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+				t.Fatalf("%s %s invalid status: %s", tt.method, tt.path, err)
+			}
+			if st.FilesWatched != 3 || st.Paused != tt.wantPaused {
+				t.Errorf("%s %s status = %+v", tt.method, tt.path, st)
+			}
+		})
+	}
+}
+
+func TestAPI_trigger_runInProgress(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 2)
+	d := daemon.New(
+		daemon.WithBasePath(t.TempDir()),
+		daemon.WithCommand("sleep 30"),
+		daemon.WithFrequency(60),
+		daemon.WithLogWriter(ioutil.Discard),
+		daemon.WithEventHandler(event.HandlerFunc(func(e event.Event) {
+			if e.Type == event.RunStarted {
+				started <- struct{}{}
+			}
+		})),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	watchDone := make(chan struct{})
+	go func() {
+		d.Watch(ctx, make(chan os.Signal))
+		close(watchDone)
+	}()
+	defer func() {
+		cancel()
+		<-watchDone
+	}()
+
+	srv := httptest.NewServer(api.New(d))
+	defer srv.Close()
+	client := &http.Client{Timeout: time.Second}
+	trigger := func() {
+		t.Helper()
+		resp, err := client.Post(srv.URL+"/trigger", "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST /trigger error = %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("POST /trigger code = %d, want %d", resp.StatusCode, http.StatusAccepted)
+		}
+	}
+
+	trigger()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("POST /trigger did not start a run")
+	}
+	// the command is running for 30s, the next run is queued
+	trigger()
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"net"
+	"strings"
+)
+
+const unixPrefix = "unix:"
+
+// Listen listens on a TCP address (eg localhost:8080) or, when the address
+// starts with unix:, on a Unix socket (eg unix:/tmp/watcher.sock).
+func Listen(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("unix", strings.TrimPrefix(addr, unixPrefix))
+	}
+	return net.Listen("tcp", addr)
+}
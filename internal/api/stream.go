@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// subscriber buffer, a subscriber falling further behind is disconnected
+// and expected to resume from its last sequence number
+const subscriberBuffer = 64
+
+const keepAlive = 30 * time.Second
+
+// gapEvent is sent to a resuming client which missed events no longer kept,
+// with the sequence numbers it resumed after and of the next event it gets.
+const gapEvent = "gap"
+
+// gap tells a resuming client the events it cannot receive.
+type gap struct {
+	After uint64 `json:"after"`
+	Next  uint64 `json:"next"`
+}
+
+// Stream keeps the recent events and pushes new ones to connected clients
+// as Server-Sent Events.
+type Stream struct {
+	mux     *sync.Mutex
+	size    int
+	backlog []event.Event
+	// the sequence number of the latest event
+	last uint64
+	subs map[chan event.Event]struct{}
+}
+
+// NewStream is a constructor providing a new Stream remembering
+// up to size recent events for resuming clients. With zero size
+// no events are kept.
+func NewStream(size int) *Stream {
+	if size < 0 {
+		size = 0
+	}
+	return &Stream{
+		mux:     &sync.Mutex{},
+		size:    size,
+		backlog: make([]event.Event, 0, size),
+		subs:    map[chan event.Event]struct{}{},
+	}
+}
+
+// Handle stores the event and passes it to the connected clients.
+func (s *Stream) Handle(e event.Event) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.last = e.Seq
+	if s.size > 0 {
+		if len(s.backlog) == s.size {
+			s.backlog = s.backlog[1:]
+		}
+		s.backlog = append(s.backlog, e)
+	}
+
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a new client, providing the stored events with
+// a sequence number higher than since and, when some of the events after
+// since are no longer kept, the gap.
+func (s *Stream) subscribe(since uint64) (chan event.Event, []event.Event, *gap) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	missed := []event.Event{}
+	for _, e := range s.backlog {
+		if e.Seq > since {
+			missed = append(missed, e)
+		}
+	}
+	var g *gap
+	if since > 0 && since < s.last {
+		next := s.last + 1
+		if len(missed) > 0 {
+			next = missed[0].Seq
+		}
+		if next > since+1 {
+			g = &gap{After: since, Next: next}
+		}
+	}
+	ch := make(chan event.Event, subscriberBuffer)
+	s.subs[ch] = struct{}{}
+
+	return ch, missed, g
+}
+
+func (s *Stream) unsubscribe(ch chan event.Event) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// ServeHTTP streams the events. Supported query parameters:
+//
+//	rule   comma separated rule names
+//	type   comma separated event types, eg change_detected,run_finished
+//	kind   comma separated change kinds, eg created,deleted
+//	since  sequence number to resume after, the Last-Event-ID header
+//	       sent by reconnecting clients is used when not provided
+//
+// A resuming client which missed events no longer kept first gets
+// a gap event.
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	since, err := parseSince(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f := newFilter(r)
+
+	ch, missed, g := s.subscribe(since)
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	if g != nil {
+		if b, err := json.Marshal(g); err == nil {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", gapEvent, b)
+		}
+	}
+
+	for _, e := range missed {
+		writeEvent(w, f, e)
+	}
+	flusher.Flush()
+
+	tick := time.NewTicker(keepAlive)
+	defer tick.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeEvent(w, f, e)
+		case <-tick.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func parseSince(r *http.Request) (uint64, error) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		v = r.Header.Get("Last-Event-ID")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	since, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sequence number %q", v)
+	}
+	return since, nil
+}
+
+func writeEvent(w http.ResponseWriter, f filter, e event.Event) {
+	if !f.match(e) {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, b)
+}
+
+// filter selects events by rule, type and change kind. An empty set
+// matches everything.
+type filter struct {
+	rules map[string]bool
+	types map[string]bool
+	kinds map[string]bool
+}
+
+func newFilter(r *http.Request) filter {
+	q := r.URL.Query()
+	return filter{
+		rules: toSet(q.Get("rule")),
+		types: toSet(q.Get("type")),
+		kinds: toSet(q.Get("kind")),
+	}
+}
+
+func (f filter) match(e event.Event) bool {
+	if len(f.rules) != 0 && !f.rules[e.Rule] {
+		return false
+	}
+	if len(f.types) != 0 && !f.types[string(e.Type)] {
+		return false
+	}
+	if len(f.kinds) != 0 && (e.Change == nil || !f.kinds[string(e.Change.Kind)]) {
+		return false
+	}
+	return true
+}
+
+func toSet(v string) map[string]bool {
+	set := map[string]bool{}
+	for _, p := range strings.Split(v, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			set[p] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,147 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/api"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestStream_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	s := api.NewStream(10)
+	s.Handle(event.Event{Seq: 1, Rule: "app", Type: event.ScanStarted})
+	s.Handle(event.Event{Seq: 2, Rule: "app", Type: event.ChangeDetected, Change: &event.Change{Kind: event.Created}})
+	s.Handle(event.Event{Seq: 3, Rule: "lib", Type: event.ChangeDetected, Change: &event.Change{Kind: event.Created}})
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?rule=app&kind=created,modified", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events error = %s", err)
+	}
+	defer resp.Body.Close()
+
+	go s.Handle(event.Event{Seq: 4, Rule: "app", Type: event.ChangeDetected, Change: &event.Change{Kind: event.Modified}})
+
+	ids := []string{}
+	sc := bufio.NewScanner(resp.Body)
+	for len(ids) < 2 && sc.Scan() {
+		if strings.HasPrefix(sc.Text(), "id: ") {
+			ids = append(ids, strings.TrimPrefix(sc.Text(), "id: "))
+		}
+	}
+	if strings.Join(ids, ",") != "2,4" {
+		t.Errorf("Stream.ServeHTTP() streamed ids %v, want [2 4]", ids)
+	}
+}
+
+func TestStream_Handle_noBacklog(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{0, -1} {
+		s := api.NewStream(size)
+		s.Handle(event.Event{Seq: 1, Type: event.ScanStarted})
+		s.Handle(event.Event{Seq: 2, Type: event.ScanFinished})
+
+		// nothing is replayed, the missed event is reported as a gap
+		got := resume(t, s, 1, 3, 2)
+		if want := []string{`gap {"after":1,"next":3}`, "3"}; strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("NewStream(%d) streamed %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestStream_ServeHTTP_resume(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		since uint64
+		want  []string
+	}{
+		{name: "within the backlog", since: 2, want: []string{"3", "4", "5"}},
+		{name: "before the backlog", since: 1, want: []string{`gap {"after":1,"next":3}`, "3", "4", "5"}},
+		{name: "up to date", since: 4, want: []string{"5"}},
+		{name: "new client", want: []string{"3", "4", "5"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := api.NewStream(2)
+			for seq := uint64(1); seq <= 4; seq++ {
+				s.Handle(event.Event{Seq: seq, Type: event.ScanStarted})
+			}
+			if got := resume(t, s, tt.since, 5, len(tt.want)); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Stream.ServeHTTP() streamed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// resume subscribes to the stream resuming after since, handles the live
+// event with the sequence number and provides the ids of the first n events
+// streamed, or the names and data of those without an id.
+func resume(t *testing.T, s *api.Stream, since, live uint64, n int) []string {
+	t.Helper()
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since > 0 {
+		req.Header.Set("Last-Event-ID", fmt.Sprint(since))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET events error = %s", err)
+	}
+	defer resp.Body.Close()
+
+	go s.Handle(event.Event{Seq: live, Type: event.ScanFinished})
+
+	got := []string{}
+	id, name := "", ""
+	sc := bufio.NewScanner(resp.Body)
+	for len(got) < n && sc.Scan() {
+		switch line := sc.Text(); {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: ") && id == "":
+			name += " " + strings.TrimPrefix(line, "data: ")
+		case line == "":
+			if id != "" {
+				got = append(got, id)
+			} else if name != "" {
+				got = append(got, name)
+			}
+			id, name = "", ""
+		}
+	}
+	return got
+}
@@ -0,0 +1,137 @@
+// Package banner separates the output of consecutive runs with a banner
+// showing what triggered the run and a footer showing its outcome.
+package banner
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/terminal"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// number of changed files listed in the banner
+const maxTriggers = 3
+
+// ANSI colours
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorGreen = "\033[32m"
+	colorRed   = "\033[31m"
+)
+
+// Printer prints the banners and footers.
+type Printer struct {
+	out         io.Writer
+	clearScreen bool
+	color       bool
+
+	mux     *sync.Mutex
+	run     int
+	started time.Time
+	pending []string
+}
+
+// Option provides a way to customise the Printer.
+type Option func(*Printer)
+
+// New is a constructor providing a new Printer writing to out.
+func New(out io.Writer, ops ...Option) *Printer {
+	p := &Printer{
+		out: out,
+		mux: &sync.Mutex{},
+	}
+	for _, o := range ops {
+		o(p)
+	}
+	return p
+}
+
+// WithClearScreen clears the terminal before each run.
+func WithClearScreen(c bool) Option {
+	return func(p *Printer) {
+		p.clearScreen = c
+	}
+}
+
+// WithColor colours the banners and footers.
+func WithColor(c bool) Option {
+	return func(p *Printer) {
+		p.color = c
+	}
+}
+
+// Handle prints the banner when a run starts and the footer when it finishes.
+func (p *Printer) Handle(e event.Event) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	switch e.Type {
+	case event.ChangeDetected:
+		p.pending = append(p.pending, e.Change.Path)
+	case event.RunStarted:
+		p.run++
+		p.started = e.Time
+		p.printBanner(e.Time)
+		p.pending = nil
+	case event.Readiness:
+		switch e.Run.State {
+		case event.Ready:
+			p.printFooter(true, fmt.Sprintf("ready after %s", roundDuration(e.Time.Sub(p.started))))
+		case event.Failed:
+			p.printFooter(false, "failed to become ready")
+		}
+	case event.RunFinished:
+		if e.Run.Restart {
+			return
+		}
+		p.printFooter(e.Run.ExitCode == 0,
+			fmt.Sprintf("exit code %d in %s", e.Run.ExitCode, roundDuration(e.Run.Duration)))
+	}
+}
+
+func (p *Printer) printBanner(at time.Time) {
+	if p.clearScreen {
+		fmt.Fprint(p.out, terminal.ClearScreen)
+	}
+	title := fmt.Sprintf("━━━ run #%d ━━━ %s ━━━", p.run, at.Format("2006-01-02 15:04:05"))
+	fmt.Fprintln(p.out, p.paint(colorBold, title))
+	fmt.Fprintf(p.out, "trigger: %s\n\n", triggers(p.pending))
+}
+
+func (p *Printer) printFooter(ok bool, outcome string) {
+	mark, color := "✔", colorGreen
+	if !ok {
+		mark, color = "✘", colorRed
+	}
+	fmt.Fprintf(p.out, "\n%s\n", p.paint(color, fmt.Sprintf("━━━ %s run #%d: %s ━━━", mark, p.run, outcome)))
+}
+
+func (p *Printer) paint(color, s string) string {
+	if !p.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// triggers describes the changed files, listing only the first few.
+func triggers(paths []string) string {
+	if len(paths) == 0 {
+		return "manual"
+	}
+	if len(paths) <= maxTriggers {
+		return strings.Join(paths, ", ")
+	}
+	return fmt.Sprintf("%s (+%d more)", strings.Join(paths[:maxTriggers], ", "), len(paths)-maxTriggers)
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Millisecond)
+}
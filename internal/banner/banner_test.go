@@ -0,0 +1,67 @@
+package banner_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/banner"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestPrinter_Handle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		color    bool
+		clear    bool
+		changes  []string
+		exitCode int
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "plain success",
+			changes:  []string{"a.go", "b.go", "c.go", "d.go", "e.go"},
+			want:     []string{"run #1", "trigger: a.go, b.go, c.go (+2 more)", "✔ run #1: exit code 0 in 1.5s"},
+			notWant:  []string{"\033["},
+			exitCode: 0,
+		},
+		{
+			name:     "coloured failure with clearing",
+			color:    true,
+			clear:    true,
+			want:     []string{"\033[H\033[2J", "trigger: manual", "\033[31m━━━ ✘ run #1: exit code 2"},
+			exitCode: 2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			p := banner.New(buf, banner.WithColor(tt.color), banner.WithClearScreen(tt.clear))
+
+			for _, c := range tt.changes {
+				p.Handle(event.Event{Type: event.ChangeDetected, Change: &event.Change{Path: c}})
+			}
+			p.Handle(event.Event{Type: event.RunStarted, Time: time.Now(), Run: &event.Run{}})
+			p.Handle(event.Event{
+				Type: event.RunFinished,
+				Run:  &event.Run{ExitCode: tt.exitCode, Duration: 1500 * time.Millisecond},
+			})
+
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("Printer output missing %q in\n%q", w, buf.String())
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(buf.String(), w) {
+					t.Errorf("Printer output contains %q in\n%q", w, buf.String())
+				}
+			}
+		})
+	}
+}
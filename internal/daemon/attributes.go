@@ -0,0 +1,17 @@
+package daemon
+
+import (
+	"os"
+)
+
+// setAttributes sets the tracked attributes of the file.
+func (d *Daemon) setAttributes(fi *FileInfo, info os.FileInfo) {
+	if !d.attributes {
+		return
+	}
+	fi.Mode = info.Mode()
+	fi.UID, fi.GID = fileOwner(info)
+	if d.xattrs {
+		fi.Xattrs = hashXattrs(fi.Path)
+	}
+}
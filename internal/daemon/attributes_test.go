@@ -0,0 +1,49 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+)
+
+func TestDaemon_CollectFiles_attributes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "run.go")
+	if err := ioutil.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(file, 0o751); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		track    bool
+		wantMode os.FileMode
+	}{
+		{name: "not tracked"},
+		{name: "tracked", track: true, wantMode: 0o751},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			d := daemon.New(daemon.WithBasePath(dir), daemon.WithAttributes(tt.track, false))
+			files, err := d.CollectFiles(context.Background())
+			if err != nil {
+				t.Fatalf("Daemon.CollectFiles() error = %s", err)
+			}
+			if len(files) != 1 || files[0].Mode != tt.wantMode {
+				t.Errorf("Daemon.CollectFiles() = %+v, want mode %s", files, tt.wantMode)
+			}
+			if tt.track && files[0].UID != uint32(os.Getuid()) {
+				t.Errorf("Daemon.CollectFiles() uid = %d, want %d", files[0].UID, os.Getuid())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"go/build"
+	"path/filepath"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// buildContext provides the context the build constraints are evaluated
+// against.
+func buildContext(goos, goarch string, tags []string) *build.Context {
+	ctx := build.Default
+	if goos != "" {
+		ctx.GOOS = goos
+	}
+	if goarch != "" {
+		ctx.GOARCH = goarch
+	}
+	ctx.BuildTags = tags
+	return &ctx
+}
+
+// matchBuild marks the Go files of the next snapshot which are not part of
+// the build, by their name (eg foo_windows.go) or go:build lines. The result
+// for a file which did not change size nor modification time is taken over
+// from the previous snapshot. Files which cannot be evaluated are part of
+// the build.
+func matchBuild(ctx *build.Context, prev, next Snapshot) {
+	for p, f := range next {
+		if f.Dir || filepath.Ext(p) != ".go" {
+			continue
+		}
+		if old, ok := prev[p]; ok && old.Size == f.Size && old.ModTime.Equal(f.ModTime) {
+			f.OutOfBuild = old.OutOfBuild
+		} else {
+			match, err := ctx.MatchFile(filepath.Dir(p), filepath.Base(p))
+			f.OutOfBuild = err == nil && !match
+		}
+		next[p] = f
+	}
+}
+
+// dropIrrelevant removes the changes of the Go files which are not part of
+// the build, neither before nor after the change. A change of a file leaving
+// or joining the build is kept.
+func (d *Daemon) dropIrrelevant(changes []event.Change, prev, next Snapshot) []event.Change {
+	if d.build == nil {
+		return changes
+	}
+	inBuild := func(s Snapshot, path string) bool {
+		f, ok := s[path]
+		return ok && !f.OutOfBuild
+	}
+
+	kept := changes[:0]
+	for _, c := range changes {
+		if filepath.Ext(c.Path) == ".go" && !inBuild(next, c.Path) && !inBuild(prev, c.Path) &&
+			(c.OldPath == "" || !inBuild(prev, c.OldPath)) {
+			d.debugf("ignoring %s %s, not part of the build\n", c.Path, c.Kind)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
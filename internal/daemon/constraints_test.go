@@ -0,0 +1,53 @@
+package daemon_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+)
+
+func TestDaemon_Watch_buildConstraints(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("util.go", "package main\n")
+	write("util_windows.go", "package main\n")
+
+	w := watch(t, daemon.WithBasePath(dir), daemon.WithBuildConstraints("linux", "amd64", []string{"extra"}))
+
+	write("main_linux.go", "package main\n")
+	write("main_windows.go", "package main\n")
+	write("main_arm64.go", "package main\n")
+	write("integration.go", "//go:build integration\n\npackage main\n")
+	write("extra.go", "//go:build extra && !windows\n\npackage main\n")
+	write("util.go", "//go:build integration\n\npackage main\n")
+	write("util_windows.go", "package main\n\nfunc util() {}\n")
+	w.waitScan()
+	// util.go has been left out of the build
+	write("util.go", "//go:build integration\n\npackage main\n\nfunc util() {}\n")
+	if err := os.Remove(filepath.Join(dir, "main_windows.go")); err != nil {
+		t.Fatal(err)
+	}
+	w.waitScan()
+
+	changes := []string{}
+	for _, c := range w.stop() {
+		changes = append(changes, filepath.Base(c.Path)+" "+string(c.Kind))
+	}
+	sort.Strings(changes)
+	want := []string{"extra.go created", "main_linux.go created", "util.go modified"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Daemon.Watch() reported %v, want %v", changes, want)
+	}
+}
@@ -0,0 +1,246 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// ReloadFunc provides the options of a freshly read configuration.
+type ReloadFunc func() ([]Option, error)
+
+// Status captures the configuration and the runtime state of the daemon.
+type Status struct {
+	Rule         string     `json:"rule"`
+	BasePath     string     `json:"base_path"`
+	BasePaths    []string   `json:"base_paths,omitempty"`
+	Extension    string     `json:"extension"`
+	Excluded     []string   `json:"excluded"`
+	Frequency    int32      `json:"frequency"`
+	Command      string     `json:"command"`
+	Restart      bool       `json:"restart"`
+	Readiness    *Probe     `json:"readiness,omitempty"`
+	Paused       bool       `json:"paused"`
+	FilesWatched int        `json:"files_watched"`
+	LastRun      *RunStatus `json:"last_run,omitempty"`
+}
+
+// RunStatus describes the last run of the command.
+type RunStatus struct {
+	Args     []string      `json:"args"`
+	Started  time.Time     `json:"started"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration_ns"`
+	// State is the readiness of the process in the restart mode.
+	State event.State `json:"state,omitempty"`
+}
+
+// Status provides the current configuration and state of the daemon.
+func (d *Daemon) Status() Status {
+	d.cfgMux.RLock()
+	st := Status{
+		Rule:      d.Rule,
+		BasePath:  d.BasePath,
+		BasePaths: d.BasePaths,
+		Extension: d.Extention,
+		Excluded:  d.Excluded,
+		Frequency: d.Frequency,
+		Command:   d.Command,
+		Restart:   d.Restart,
+		Readiness: d.Readiness,
+	}
+	d.cfgMux.RUnlock()
+
+	d.stateMux.Lock()
+	defer d.stateMux.Unlock()
+	st.Paused = d.paused
+	st.FilesWatched = d.filesWatched
+	st.LastRun = d.lastRun
+
+	return st
+}
+
+// Trigger forces a run of the command. The optional changes are reported
+// as detected changes before the run. It queues the run without waiting for
+// the run in progress, if any, to finish. Triggers made while a run is
+// already queued are coalesced into it.
+func (d *Daemon) Trigger(ctx context.Context, changes []string) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "cannot trigger a run")
+	}
+	if d.quitting() {
+		return errors.New("cannot trigger a run, the daemon is quitting")
+	}
+
+	for _, c := range changes {
+		d.emit(event.Event{
+			Type:   event.ChangeDetected,
+			Change: &event.Change{Path: c, Kind: event.Modified},
+		})
+	}
+	d.requestRun()
+	return nil
+}
+
+// Pause stops checking for changes until Resume is called.
+func (d *Daemon) Pause() {
+	d.stateMux.Lock()
+	d.paused = true
+	d.stateMux.Unlock()
+}
+
+// Resume restarts checking for changes.
+func (d *Daemon) Resume() {
+	d.stateMux.Lock()
+	d.paused = false
+	d.stateMux.Unlock()
+}
+
+// Quit stops the running command and makes Watch return.
+func (d *Daemon) Quit() {
+	d.quitOnce.Do(func() {
+		close(d.quitCh)
+	})
+}
+
+func (d *Daemon) quitting() bool {
+	select {
+	case <-d.quitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Verbose reports whether the progress of each file check is printed.
+func (d *Daemon) Verbose() bool {
+	d.stateMux.Lock()
+	defer d.stateMux.Unlock()
+	return d.verbose
+}
+
+// SetVerbose turns printing of the progress of each file check on or off.
+func (d *Daemon) SetVerbose(v bool) {
+	d.stateMux.Lock()
+	d.verbose = v
+	d.stateMux.Unlock()
+}
+
+// Reload rereads the configuration using the function provided
+// by WithReloadFunc and hands it over to the running watcher.
+func (d *Daemon) Reload(ctx context.Context) error {
+	if d.reload == nil {
+		return errors.New("reloading of configuration is not set up")
+	}
+	ops, err := d.reload()
+	if err != nil {
+		return errors.Wrap(err, "cannot reload configuration")
+	}
+
+	select {
+	case d.reloadCh <- New(ops...):
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "cannot reload configuration")
+	}
+}
+
+// applyConfig copies the configuration of the reloaded daemon. A change
+// of the watched files, or of how they are tracked, starts from a new
+// snapshot, as it discards a persisted one.
+func (d *Daemon) applyConfig(nd *Daemon) {
+	d.cfgMux.Lock()
+	defer d.cfgMux.Unlock()
+
+	if !d.tracking().equal(nd.tracking()) {
+		d.snapshot = nil
+		d.modRoots, d.modStamp = nil, ""
+	}
+
+	d.Rule = nd.Rule
+	d.BasePath = nd.BasePath
+	d.BasePaths = nd.BasePaths
+	d.Extention = nd.Extention
+	d.Excluded = nd.Excluded
+	d.Frequency = nd.Frequency
+	d.frequency = nd.frequency
+	d.maxHashSize = nd.maxHashSize
+	d.editorPatterns = nd.editorPatterns
+	d.attributes = nd.attributes
+	d.xattrs = nd.xattrs
+	d.directories = nd.directories
+	d.followSymlinks = nd.followSymlinks
+	d.symlinkRoots = nd.symlinkRoots
+	d.moduleRoots = nd.moduleRoots
+	d.embedAssets = nd.embedAssets
+	d.build = nd.build
+	d.outputs = nd.outputs
+	d.stableFor = nd.stableFor
+	d.maxStableWait = nd.maxStableWait
+	d.Command = nd.Command
+	d.Restart = nd.Restart
+	d.Readiness = nd.Readiness
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Daemon) commandParts() []string {
+	d.cfgMux.RLock()
+	defer d.cfgMux.RUnlock()
+	return strings.Split(d.Command, " ")
+}
+
+func (d *Daemon) restartMode() bool {
+	d.cfgMux.RLock()
+	defer d.cfgMux.RUnlock()
+	return d.Restart
+}
+
+func (d *Daemon) debugf(format string, a ...interface{}) {
+	if d.Verbose() {
+		fmt.Fprintf(d.logOut, format, a...)
+	}
+}
+
+func (d *Daemon) isPaused() bool {
+	d.stateMux.Lock()
+	defer d.stateMux.Unlock()
+	return d.paused
+}
+
+func (d *Daemon) setFilesWatched(n int) {
+	d.stateMux.Lock()
+	d.filesWatched = n
+	d.stateMux.Unlock()
+}
+
+func (d *Daemon) setLastRun(start time.Time, r *event.Run) {
+	d.stateMux.Lock()
+	defer d.stateMux.Unlock()
+
+	state := r.State
+	if state == "" && d.lastRun != nil && d.lastRun.Started.Equal(start) {
+		state = d.lastRun.State
+	}
+	d.lastRun = &RunStatus{
+		Args:     r.Args,
+		Started:  start,
+		ExitCode: r.ExitCode,
+		Duration: r.Duration,
+		State:    state,
+	}
+}
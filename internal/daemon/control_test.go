@@ -0,0 +1,139 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestDaemon_Quit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		restart bool
+	}{
+		{name: "run in progress"},
+		{name: "restart mode", restart: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			d := daemon.New(
+				daemon.WithBasePath("fixtures/basepath/subdir1"),
+				daemon.WithCommand("sleep 30"),
+				daemon.WithFrequency(60),
+				daemon.WithRestart(tt.restart),
+				daemon.WithLogWriter(ioutil.Discard),
+			)
+
+			watchDone := make(chan struct{})
+			go func() {
+				d.Watch(context.Background(), make(chan os.Signal))
+				close(watchDone)
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := d.Trigger(ctx, nil); err != nil {
+				t.Fatalf("Daemon.Trigger() error = %s", err)
+			}
+			// let the command start
+			time.Sleep(200 * time.Millisecond)
+
+			start := time.Now()
+			d.Quit()
+			select {
+			case <-watchDone:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Daemon.Watch() did not return after Quit")
+			}
+			if time.Since(start) > 3*time.Second {
+				t.Errorf("Daemon.Quit() waited %s for the command", time.Since(start))
+			}
+			if st := d.Status(); st.LastRun == nil || st.LastRun.Args[0] != "sleep" {
+				t.Errorf("Daemon.Status() last run = %+v", st.LastRun)
+			}
+		})
+	}
+}
+
+func TestDaemon_Watch_cancelWithQueuedTrigger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		restart bool
+	}{
+		{name: "run in progress"},
+		{name: "restart mode", restart: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := &sync.Mutex{}
+			started := []time.Time{}
+			d := daemon.New(
+				daemon.WithBasePath("fixtures/basepath/subdir1"),
+				daemon.WithCommand("sleep 1"),
+				daemon.WithFrequency(60),
+				daemon.WithRestart(tt.restart),
+				daemon.WithLogWriter(ioutil.Discard),
+				daemon.WithEventHandler(event.HandlerFunc(func(e event.Event) {
+					if e.Type == event.RunStarted {
+						mux.Lock()
+						started = append(started, time.Now())
+						mux.Unlock()
+					}
+				})),
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			watchDone := make(chan struct{})
+			go func() {
+				d.Watch(ctx, make(chan os.Signal))
+				close(watchDone)
+			}()
+
+			triggerCtx, cancelTrigger := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancelTrigger()
+			if err := d.Trigger(triggerCtx, nil); err != nil {
+				t.Fatalf("Daemon.Trigger() error = %s", err)
+			}
+			// let the command start and queue another run
+			time.Sleep(200 * time.Millisecond)
+			go func() {
+				//nolint:errcheck
+				d.Trigger(triggerCtx, nil)
+			}()
+			time.Sleep(100 * time.Millisecond)
+
+			cancelled := time.Now()
+			cancel()
+			select {
+			case <-watchDone:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Daemon.Watch() did not return after the context was cancelled")
+			}
+			if waited := time.Since(cancelled); waited > 500*time.Millisecond {
+				t.Errorf("Daemon.Watch() returned %s after the context was cancelled", waited)
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+			for _, s := range started {
+				if s.After(cancelled) {
+					t.Errorf("Daemon.Watch() started a run %s after the context was cancelled", s.Sub(cancelled))
+				}
+			}
+		})
+	}
+}
@@ -1,25 +1,121 @@
 package daemon
 
 import (
+	"go/build"
+	"io"
+	"os"
 	"sync"
 	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
 )
 
+// BackendPoll is the name of the change detection mechanism walking
+// through the files at regular intervals. It is currently the only one.
+const BackendPoll = "poll"
+
 // Daemon contains configuriation for running the watcher
 type Daemon struct {
+	Rule      string
 	BasePath  string
+	BasePaths []string
 	Extention string
 	Excluded  []string
 	Frequency int32
 	frequency time.Duration
+	// files up to the size are confirmed as modified by a hash of their
+	// content, zero turns the hashing off
+	maxHashSize int64
+	// track the mode and owner, and the extended attributes of the files
+	attributes bool
+	xattrs     bool
+	// track the directories below the base paths too
+	directories bool
+	// follow symlinks to targets within the base path or the symlinkRoots,
+	// or anywhere without the roots
+	followSymlinks bool
+	symlinkRoots   []string
+	// watch the local modules referenced by go.work and go.mod too
+	moduleRoots bool
+	// watch the files embedded by the go:embed directives too
+	embedAssets bool
+	// the changes of the Go files left out of the build are ignored
+	build *build.Context
+	// globs of the names of editor temporary files, which are not watched
+	editorPatterns []string
+	// globs of the files written by the command, which do not trigger a run
+	// when written while the command is running
+	outputs []string
+	// changed files are reported once they have not been modified for
+	// stableFor, or after maxStableWait
+	stableFor     time.Duration
+	maxStableWait time.Duration
 
 	// mutex protects sending on the doneChan
 	doneMux  *sync.Mutex
 	doneChan chan struct{}
+	// a pending request for a run after detected changes
+	runReq chan struct{}
+	// closed by Quit
+	quitCh   chan struct{}
+	quitOnce *sync.Once
 
 	// mutex protects running of the command
 	cmdMux  *sync.Mutex
 	Command string
+	// Restart runs the command as a long running process (eg a server),
+	// which is restarted when a change is detected
+	Restart bool
+	// Readiness checks when the restarted process is ready
+	Readiness *Probe
+	// mutex protects the running process and the log of the last run
+	procMux *sync.Mutex
+	proc    *process
+	lastLog *tailBuffer
+
+	// logOut receives the human readable output of the daemon
+	// and of the command, errOut the error output of the command
+	logOut io.Writer
+	errOut io.Writer
+	// outputLog captures the output of each run
+	outputLog OutputLog
+	handlers  []event.Handler
+	// mutex keeps the events ordered by their sequence number
+	emitMux *sync.Mutex
+	seq     uint64
+
+	// mutex protects the configuration while it is being reloaded
+	cfgMux   *sync.RWMutex
+	reload   ReloadFunc
+	reloadCh chan *Daemon
+
+	// the snapshot of the watched files is only used by the Watch goroutine
+	snapshot      Snapshot
+	restored      bool
+	snapshotSaved time.Time
+	stateFile     string
+	catchUp       bool
+	unstableSince map[string]time.Time
+	deletedSince  map[string]time.Time
+	// the directories holding editor temporary files at the latest scan
+	editorDirs map[string]bool
+	// the discovered local modules, the state of the files they were read
+	// from and whether they changed since the previous scan
+	modRoots     []string
+	modStamp     string
+	rootsChanged bool
+	// the go:embed directives of the Go files and the files which started
+	// (true) or stopped (false) being embedded as the directives changed
+	embeds        map[string]embedDirectives
+	embedsChanged map[string]bool
+
+	// mutex protects the runtime state reported by Status
+	stateMux     *sync.Mutex
+	verbose      bool
+	paused       bool
+	filesWatched int
+	lastRun      *RunStatus
+	runWindows   []runWindow
 }
 
 // Option provides a way to customise the
@@ -29,6 +125,7 @@ type Option func(*Daemon)
 func New(ops ...Option) *Daemon {
 	f := int32(15)
 	d := &Daemon{
+		Rule:      "default",
 		BasePath:  ".",
 		Extention: ".go",
 		Excluded:  []string{},
@@ -37,9 +134,27 @@ func New(ops ...Option) *Daemon {
 
 		cmdMux:  &sync.Mutex{},
 		Command: "echo \"Hello world\"",
+		procMux: &sync.Mutex{},
 
 		doneMux:  &sync.Mutex{},
 		doneChan: make(chan struct{}),
+		runReq:   make(chan struct{}, 1),
+		quitCh:   make(chan struct{}),
+		quitOnce: &sync.Once{},
+
+		logOut:   os.Stdout,
+		errOut:   os.Stderr,
+		handlers: []event.Handler{},
+		emitMux:  &sync.Mutex{},
+
+		editorPatterns: DefaultEditorPatterns,
+		unstableSince:  map[string]time.Time{},
+		deletedSince:   map[string]time.Time{},
+
+		cfgMux:   &sync.RWMutex{},
+		reloadCh: make(chan *Daemon),
+		stateMux: &sync.Mutex{},
+		verbose:  true,
 	}
 
 	for _, o := range ops {
@@ -49,6 +164,14 @@ func New(ops ...Option) *Daemon {
 	return d
 }
 
+// WithRule allows to name the configuration. The name is reported
+// in the emitted events.
+func WithRule(name string) Option {
+	return func(d *Daemon) {
+		d.Rule = name
+	}
+}
+
 // WithBasePath allows to override default BasePath configuration.
 func WithBasePath(bp string) Option {
 	return func(d *Daemon) {
@@ -56,6 +179,19 @@ func WithBasePath(bp string) Option {
 	}
 }
 
+// WithBasePaths allows to watch several base paths, eg on different file
+// systems. The first one becomes the BasePath. The duplicate base paths
+// and those within another one are watched once.
+func WithBasePaths(bps []string) Option {
+	return func(d *Daemon) {
+		if len(bps) == 0 {
+			return
+		}
+		d.BasePath = bps[0]
+		d.BasePaths = bps[1:]
+	}
+}
+
 // WithExtension allows to override default file extension configuration.
 func WithExtension(ex string) Option {
 	return func(d *Daemon) {
@@ -71,6 +207,23 @@ func WithCommand(c string) Option {
 	}
 }
 
+// WithRestart allows to run the command as a long running process,
+// which is stopped and started again when a change is detected.
+func WithRestart(r bool) Option {
+	return func(d *Daemon) {
+		d.Restart = r
+	}
+}
+
+// WithReadiness allows to provide a check deciding when the process
+// started in the restart mode is ready. Without it the process is ready
+// as soon as it starts.
+func WithReadiness(p *Probe) Option {
+	return func(d *Daemon) {
+		d.Readiness = p
+	}
+}
+
 // WithExcluded allows to provide a list of paths to exclude.
 func WithExcluded(ex []string) Option {
 	return func(d *Daemon) {
@@ -85,3 +238,199 @@ func WithFrequency(f int32) Option {
 		d.frequency = time.Duration(time.Duration(f) * time.Second)
 	}
 }
+
+// WithStateFile allows to persist the snapshot of the watched files, so that
+// the changes made while the daemon was not running are detected on start.
+// The snapshot is saved periodically and on shutdown.
+func WithStateFile(path string) Option {
+	return func(d *Daemon) {
+		d.stateFile = path
+	}
+}
+
+// WithCatchUp allows to run the command on start when the watched files
+// changed since the snapshot was saved.
+func WithCatchUp(c bool) Option {
+	return func(d *Daemon) {
+		d.catchUp = c
+	}
+}
+
+// WithContentHash allows to confirm that a file was modified by hashing its
+// content, so that a file rewritten with the same content does not trigger
+// a run. Only files up to maxSize bytes are hashed, zero turns hashing off.
+func WithContentHash(maxSize int64) Option {
+	return func(d *Daemon) {
+		d.maxHashSize = maxSize
+	}
+}
+
+// WithAttributes allows to track the mode and owner of the files and,
+// with xattrs, their extended attributes (on Linux). A change of the
+// attributes is reported as event.AttributesChanged.
+func WithAttributes(track, xattrs bool) Option {
+	return func(d *Daemon) {
+		d.attributes = track
+		d.xattrs = track && xattrs
+	}
+}
+
+// WithDirectories allows to track the directories below the base paths,
+// whatever the extension. A created, deleted or renamed directory is
+// reported as event.DirCreated, event.DirDeleted or event.DirRenamed.
+func WithDirectories(track bool) Option {
+	return func(d *Daemon) {
+		d.directories = track
+	}
+}
+
+// WithModuleRoots allows to watch the local modules referenced by the go.work
+// and go.mod files in the base path, by the use directives and the replace
+// directives with a local path, besides the base paths. The modules are read
+// again when the files change.
+func WithModuleRoots(m bool) Option {
+	return func(d *Daemon) {
+		d.moduleRoots = m
+	}
+}
+
+// WithEmbeds allows to watch the files embedded by the go:embed directives of
+// the watched Go files, whatever their extension, eg templates, static assets
+// or SQL. The directives of a Go file are read again when it changes.
+func WithEmbeds(e bool) Option {
+	return func(d *Daemon) {
+		d.embedAssets = e
+	}
+}
+
+// WithBuildConstraints allows to ignore the changes of the Go files which are
+// not part of the build for the GOOS, GOARCH and build tags, by their name
+// suffixes (eg foo_windows.go) and go:build lines. Empty goos and goarch
+// default to those of the go command. A change of a file leaving the build
+// is not ignored.
+func WithBuildConstraints(goos, goarch string, tags []string) Option {
+	return func(d *Daemon) {
+		d.build = buildContext(goos, goarch, tags)
+	}
+}
+
+// WithSymlinks allows to follow symlinks: symlinked directories are walked
+// through and symlinked files are watched by their target, while the changes
+// are reported with the symlink path. A directory reached again is not walked
+// through, which breaks symlink loops. With roots given, only symlinks with
+// targets within the base path or one of the roots are followed.
+func WithSymlinks(follow bool, roots []string) Option {
+	return func(d *Daemon) {
+		d.followSymlinks = follow
+		d.symlinkRoots = roots
+	}
+}
+
+// WithEditorPatterns allows to override the globs of the names of editor
+// temporary files, which are not watched. Defaults to DefaultEditorPatterns.
+func WithEditorPatterns(globs []string) Option {
+	return func(d *Daemon) {
+		d.editorPatterns = globs
+	}
+}
+
+// WithOutputs allows to provide globs of the files written by the command,
+// eg generated code or coverage files. Their changes made while the command
+// is running do not trigger another run. In the restart mode the process runs
+// until the next change, so their changes never trigger a run while it is up.
+// The globs are matched against the path relative to the base path and
+// against the file name.
+func WithOutputs(globs []string) Option {
+	return func(d *Daemon) {
+		d.outputs = globs
+	}
+}
+
+// WithWriteStability allows to wait until a changed file is no longer being
+// written before reporting the change: it has not been modified for the stable
+// duration and, on Linux, it is not opened for writing. After maxWait the
+// change is reported anyway. Zero maxWait defaults to DefaultMaxStableWait.
+func WithWriteStability(stable, maxWait time.Duration) Option {
+	return func(d *Daemon) {
+		if maxWait <= 0 {
+			maxWait = DefaultMaxStableWait
+		}
+		d.stableFor = stable
+		d.maxStableWait = maxWait
+	}
+}
+
+// WithVerbose allows to turn off printing of the progress of each file check.
+func WithVerbose(v bool) Option {
+	return func(d *Daemon) {
+		d.verbose = v
+	}
+}
+
+// WithLogWriter allows to override where the human readable output
+// (including the output of the command) is written. Defaults to stdout.
+func WithLogWriter(w io.Writer) Option {
+	return func(d *Daemon) {
+		d.logOut = w
+	}
+}
+
+// WithErrorWriter allows to override where the error output of the command
+// is written. Defaults to stderr.
+func WithErrorWriter(w io.Writer) Option {
+	return func(d *Daemon) {
+		d.errOut = w
+	}
+}
+
+// OutputLog provides where the combined output of each run is captured.
+type OutputLog interface {
+	Open(rule string) (io.WriteCloser, error)
+}
+
+// WithOutputLog allows to capture the output of each run, besides writing it
+// to the log and error writers.
+func WithOutputLog(l OutputLog) Option {
+	return func(d *Daemon) {
+		d.outputLog = l
+	}
+}
+
+// WithEventHandler registers a handler receiving the lifecycle events.
+// Can be provided multiple times.
+func WithEventHandler(h event.Handler) Option {
+	return func(d *Daemon) {
+		d.handlers = append(d.handlers, h)
+	}
+}
+
+// WithReloadFunc provides the function reading the configuration
+// when a reload is requested.
+func WithReloadFunc(f ReloadFunc) Option {
+	return func(d *Daemon) {
+		d.reload = f
+	}
+}
+
+// emit stamps the event with the schema version, sequence number, time
+// and rule and passes it to all registered handlers.
+func (d *Daemon) emit(e event.Event) {
+	d.cfgMux.RLock()
+	e.Rule = d.Rule
+	d.cfgMux.RUnlock()
+
+	d.emitMux.Lock()
+	defer d.emitMux.Unlock()
+
+	d.seq++
+	e.Seq = d.seq
+	e.Version = event.SchemaVersion
+	e.Time = time.Now()
+	for _, h := range d.handlers {
+		h.Handle(e)
+	}
+}
+
+func (d *Daemon) emitError(err error) {
+	d.emit(event.Event{Type: event.Error, Error: err.Error()})
+}
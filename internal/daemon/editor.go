@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// DefaultEditorPatterns are the globs of the temporary files of editors,
+// matched against the file name, which are not watched by default.
+var DefaultEditorPatterns = []string{
+	// vim swap files and the file vim creates to check the directory is writable
+	"*.swp", "*.swo", "*.swx", "4913",
+	// emacs lock and auto-save files
+	".#*", "#*#",
+	// JetBrains safe write
+	"*___jb_tmp___", "*___jb_old___",
+	// backup files
+	"*~",
+}
+
+// how long a deleted file is given to reappear, when an editor saves it by
+// deleting it and renaming a temporary file to it
+const atomicSaveGrace = 100 * time.Millisecond
+
+// isEditorArtefact reports whether the file name matches any of the editor
+// patterns.
+func (d *Daemon) isEditorArtefact(name string) bool {
+	for _, p := range d.editorPatterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// holdDeleted holds back the deletions of the files next to editor temporary
+// files, keeping the deleted files in the next snapshot, so that a file saved
+// by removing it and renaming a temporary file to it is reported as modified
+// by the following scan. A file which does not reappear within the grace
+// period is reported as deleted, other deletions are reported right away.
+// It provides the changes to report and whether any is held.
+func (d *Daemon) holdDeleted(changes []event.Change, prev, next Snapshot) ([]event.Change, bool) {
+	now := time.Now()
+	for p := range d.deletedSince {
+		if _, ok := next[p]; ok {
+			// reappeared
+			delete(d.deletedSince, p)
+		}
+	}
+
+	ready := changes[:0]
+	held := false
+	for _, c := range changes {
+		if c.Kind != event.Deleted {
+			ready = append(ready, c)
+			continue
+		}
+		since, waiting := d.deletedSince[c.Path]
+		if !waiting && !d.editorDirs[filepath.Dir(c.Path)] {
+			ready = append(ready, c)
+			continue
+		}
+		if waiting && now.Sub(since) >= atomicSaveGrace {
+			delete(d.deletedSince, c.Path)
+			ready = append(ready, c)
+			continue
+		}
+		if !waiting {
+			d.deletedSince[c.Path] = now
+		}
+		next[c.Path] = prev[c.Path]
+		held = true
+	}
+	return ready, held
+}
@@ -0,0 +1,144 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestDaemon_CollectFiles_editorArtefacts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		extension string
+		files     []string
+		patterns  []string
+		want      []string
+	}{
+		{
+			name:      "default patterns",
+			extension: ".go",
+			files:     []string{"main.go", ".#main.go"},
+			want:      []string{"main.go"},
+		},
+		{
+			name:      "default patterns without extension",
+			extension: "",
+			files:     []string{"main", "4913", "#main#", "main___jb_tmp___", "main~"},
+			want:      []string{"main"},
+		},
+		{
+			name:      "overridden patterns",
+			extension: "",
+			files:     []string{"main", "4913", "#main#", "main___jb_tmp___", "main~"},
+			patterns:  []string{"*~", "4913"},
+			want:      []string{"#main#", "main", "main___jb_tmp___"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ops := []daemon.Option{daemon.WithBasePath(dir), daemon.WithExtension(tt.extension)}
+			if tt.patterns != nil {
+				ops = append(ops, daemon.WithEditorPatterns(tt.patterns))
+			}
+			files, err := daemon.New(ops...).CollectFiles(context.Background())
+			if err != nil {
+				t.Fatalf("Daemon.CollectFiles() error = %s", err)
+			}
+			got := extractNames(files)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Daemon.CollectFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_Watch_atomicSave(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		// the temporary file written while the file is removed, if any
+		temp     string
+		reappear bool
+		// whether the scan seeing the file removed, when it does not
+		// reappear, holds its deletion back
+		wantHeld  bool
+		wantKinds map[event.Kind]int
+	}{
+		{
+			name:      "renamed over",
+			temp:      "main.go~",
+			reappear:  true,
+			wantKinds: map[event.Kind]int{event.Modified: 1},
+		},
+		{
+			name:      "renamed over by JetBrains",
+			temp:      "main.go___jb_tmp___",
+			reappear:  true,
+			wantKinds: map[event.Kind]int{event.Modified: 1},
+		},
+		{name: "deleted", temp: "main.go~", wantHeld: true, wantKinds: map[event.Kind]int{event.Deleted: 1}},
+		{name: "deleted without a temporary file", wantKinds: map[event.Kind]int{event.Deleted: 1}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			file := filepath.Join(dir, "main.go")
+			if err := ioutil.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			w := watch(t, daemon.WithBasePath(dir))
+
+			// save by removing the file and renaming a temporary file to it,
+			// with a scan seeing the file removed
+			tmp := filepath.Join(dir, tt.temp)
+			if tt.temp != "" {
+				if err := ioutil.WriteFile(tmp, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Remove(file); err != nil {
+				t.Fatal(err)
+			}
+			n := w.waitScan()
+			if tt.reappear {
+				if err := os.Rename(tmp, file); err != nil {
+					t.Fatal(err)
+				}
+			} else if held := w.reportedBy(n) == 0; held != tt.wantHeld {
+				t.Errorf("Daemon.Watch() held the deletion back %t, want %t", held, tt.wantHeld)
+			}
+			w.waitScan()
+
+			kinds := map[event.Kind]int{}
+			for _, c := range w.stop() {
+				kinds[c.Kind]++
+			}
+			if !reflect.DeepEqual(kinds, tt.wantKinds) {
+				t.Errorf("Daemon.Watch() reported %v, want %v", kinds, tt.wantKinds)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// embedDirectives are the go:embed patterns of a Go file, read when
+// the file had the modification time and size.
+type embedDirectives struct {
+	modTime  time.Time
+	size     int64
+	patterns []string
+}
+
+// addEmbedded adds the files embedded by the go:embed directives of the
+// collected Go files, whatever their extension. The directives of a file
+// are only read again when it changed.
+func (d *Daemon) addEmbedded(ctx context.Context, files []FileInfo) []FileInfo {
+	collected := map[string]bool{}
+	for _, f := range files {
+		collected[f.Path] = true
+	}
+
+	embeds := map[string]embedDirectives{}
+	for _, f := range files {
+		if f.Dir || filepath.Ext(f.Path) != ".go" {
+			continue
+		}
+		ed, ok := d.embeds[f.Path]
+		if !ok || !ed.modTime.Equal(f.ModTime) || ed.size != f.Size {
+			nd := embedDirectives{modTime: f.ModTime, size: f.Size, patterns: embedPatterns(f.Path)}
+			if d.embeds != nil {
+				d.markEmbeds(filepath.Dir(f.Path), ed.patterns, nd.patterns)
+			}
+			ed = nd
+		}
+		embeds[f.Path] = ed
+
+		for _, path := range embedded(filepath.Dir(f.Path), ed.patterns) {
+			if collected[path] || d.excluded(ctx, path, filepath.Base(path)) {
+				continue
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			collected[path] = true
+			files = append(files, d.newFileInfo(path, f.Root, info))
+		}
+	}
+	for path, ed := range d.embeds {
+		if _, ok := embeds[path]; !ok {
+			d.markEmbeds(filepath.Dir(path), ed.patterns, nil)
+		}
+	}
+	d.embeds = embeds
+	return files
+}
+
+// markEmbeds records the files which started or stopped being embedded as
+// the patterns of a Go file changed from prev to next. Files matched by both
+// are left to be compared as usual.
+func (d *Daemon) markEmbeds(dir string, prev, next []string) {
+	if equal(prev, next) {
+		return
+	}
+	if d.embedsChanged == nil {
+		d.embedsChanged = map[string]bool{}
+	}
+	before := map[string]bool{}
+	for _, path := range embedded(dir, prev) {
+		before[path] = true
+	}
+	for _, path := range embedded(dir, next) {
+		if !before[path] {
+			d.embedsChanged[path] = true
+		}
+		delete(before, path)
+	}
+	for path := range before {
+		if !d.embedsChanged[path] {
+			d.embedsChanged[path] = false
+		}
+	}
+}
+
+// adoptEmbeds takes the files which started being embedded into the previous
+// snapshot as they are, and forgets the files no longer embedded, so that
+// a change of the go:embed directives is not reported as created and deleted
+// files. The files are compared as usual when they are watched otherwise too.
+func adoptEmbeds(prev, next Snapshot, changed map[string]bool) {
+	for path, embedded := range changed {
+		_, inPrev := prev[path]
+		f, inNext := next[path]
+		switch {
+		case embedded && !inPrev && inNext:
+			prev[path] = f
+		case !embedded && inPrev && !inNext:
+			delete(prev, path)
+		}
+	}
+}
+
+// embedPatterns reads the patterns of the go:embed directives of the Go file.
+// A file which cannot be read has none.
+func embedPatterns(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	patterns := []string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "//go:embed ") && !strings.HasPrefix(line, "//go:embed\t") {
+			continue
+		}
+		fields, err := moduleFields(strings.TrimPrefix(line, "//go:embed"))
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, fields...)
+	}
+	return patterns
+}
+
+// embedded provides the files matched by the go:embed patterns in the package
+// directory. The files of a matched directory are embedded recursively, except
+// those with names starting with . or _, unless the pattern starts with all:.
+func embedded(dir string, patterns []string) []string {
+	files := []string{}
+	for _, p := range patterns {
+		all := strings.HasPrefix(p, "all:")
+		p = strings.TrimPrefix(p, "all:")
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(p)))
+		if err != nil {
+			continue
+		}
+		for _, m := range matches {
+			_ = filepath.Walk(m, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				hidden := strings.HasPrefix(info.Name(), ".") || strings.HasPrefix(info.Name(), "_")
+				switch {
+				case path != m && hidden && !all && info.IsDir():
+					return filepath.SkipDir
+				case path != m && hidden && !all, info.IsDir():
+					return nil
+				}
+				files = append(files, path)
+				return nil
+			})
+		}
+	}
+	return files
+}
@@ -0,0 +1,164 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestDaemon_CollectFiles_embeds(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		embeds bool
+		source string
+		want   []string
+	}{
+		{
+			name:   "not watched",
+			source: "package web\n\n//go:embed templates/*.html\nvar templates embed.FS\n",
+			want:   []string{"web.go"},
+		},
+		{
+			name:   "globs and directories",
+			embeds: true,
+			source: "package web\n\n//go:embed templates/*.html static\n//go:embed \"sql/schema.sql\"\nvar files embed.FS\n",
+			want: []string{
+				"sql/schema.sql", "static/app.css", "static/js/app.js", "templates/index.html", "web.go",
+			},
+		},
+		{
+			name:   "hidden files",
+			embeds: true,
+			source: "package web\n\n  //go:embed all:static\nvar static embed.FS\n",
+			want:   []string{"static/.hidden", "static/_partial.css", "static/app.css", "static/js/app.js", "web.go"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			files := map[string]string{
+				"web.go":               tt.source,
+				"templates/index.html": "<html></html>",
+				"templates/notes.txt":  "not embedded",
+				"static/app.css":       "body {}",
+				"static/.hidden":       "hidden",
+				"static/_partial.css":  "p {}",
+				"static/js/app.js":     "app()",
+				"sql/schema.sql":       "CREATE TABLE t (id int);",
+				"sql/data.sql":         "not embedded",
+			}
+			for name, content := range files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d := daemon.New(daemon.WithBasePath(dir), daemon.WithEmbeds(tt.embeds))
+			collected, err := d.CollectFiles(context.Background())
+			if err != nil {
+				t.Fatalf("Daemon.CollectFiles() error = %s", err)
+			}
+			got := []string{}
+			for _, f := range collected {
+				rel, _ := filepath.Rel(dir, f.Path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Daemon.CollectFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_CollectFiles_embedsChanged(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"web.go":     "package web\n\n//go:embed a.sql\nvar a string\n",
+		"a.sql":      "SELECT 1;",
+		"b.tmpl":     "{{.}}",
+		"web_old.go": "package web\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := daemon.New(daemon.WithBasePath(dir), daemon.WithEmbeds(true))
+
+	for _, step := range []struct {
+		source string
+		want   []string
+	}{
+		{want: []string{"a.sql", "web.go", "web_old.go"}},
+		{source: "package web\n\n//go:embed b.tmpl\nvar b string\n", want: []string{"b.tmpl", "web.go", "web_old.go"}},
+	} {
+		if step.source != "" {
+			if err := ioutil.WriteFile(filepath.Join(dir, "web.go"), []byte(step.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		files, err := d.CollectFiles(context.Background())
+		if err != nil {
+			t.Fatalf("Daemon.CollectFiles() error = %s", err)
+		}
+		got := extractNames(files)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, step.want) {
+			t.Errorf("Daemon.CollectFiles() = %v, want %v", got, step.want)
+		}
+	}
+}
+
+func TestDaemon_Watch_embedsChanged(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("web.go", "package web\n\n//go:embed a.sql\nvar a string\n")
+	write("a.sql", "SELECT 1;")
+	write("b.tmpl", "{{.}}")
+
+	w := watch(t, daemon.WithBasePath(dir), daemon.WithEmbeds(true))
+
+	// the files starting and stopping being embedded are not reported
+	write("web.go", "package web\n\n//go:embed *.tmpl\nvar b embed.FS\n")
+	w.waitScan()
+	// but the changes of the newly embedded files are
+	write("b.tmpl", "{{.Name}}")
+	write("c.tmpl", "{{.}}")
+	w.waitScan()
+
+	want := []event.Change{
+		{Path: filepath.Join(dir, "b.tmpl"), Kind: event.Modified, Root: dir},
+		{Path: filepath.Join(dir, "c.tmpl"), Kind: event.Created, Root: dir},
+		{Path: filepath.Join(dir, "web.go"), Kind: event.Modified, Root: dir},
+	}
+	changes := w.stop()
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Daemon.Watch() reported %v, want %v", changes, want)
+	}
+}
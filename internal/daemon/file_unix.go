@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity provides the device and inode of the file.
+func fileIdentity(info os.FileInfo) (dev, ino uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	//nolint:unconvert
+	return uint64(st.Dev), uint64(st.Ino)
+}
+
+// fileOwner provides the user and group owning the file.
+func fileOwner(info os.FileInfo) (uid, gid uint32) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return st.Uid, st.Gid
+}
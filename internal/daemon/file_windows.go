@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package daemon
+
+import (
+	"os"
+)
+
+// fileIdentity is not available on this platform, renames are reported
+// as a deletion and a creation.
+func fileIdentity(info os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
+
+// fileOwner is not available on this platform.
+func fileOwner(info os.FileInfo) (uid, gid uint32) {
+	return 0, 0
+}
@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// moduleFiles are read for the local modules in the base path.
+var moduleFiles = []string{"go.work", "go.mod"}
+
+// ModuleRoots provides the local module directories referenced by the go.work
+// and go.mod files in the base path: the use directives and the replace
+// directives with a local path, eg replace example.com/lib => ../lib.
+// Missing files are skipped.
+func ModuleRoots(basePath string) ([]string, error) {
+	roots := []string{}
+	seen := map[string]bool{}
+	for _, name := range moduleFiles {
+		paths, err := localModules(filepath.Join(basePath, name))
+		if err != nil {
+			return roots, err
+		}
+		for _, p := range paths {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(basePath, p)
+			}
+			if !seen[p] {
+				seen[p] = true
+				roots = append(roots, p)
+			}
+		}
+	}
+	return roots, nil
+}
+
+// localModules parses the use and replace directives of a go.mod or go.work
+// file, providing the local paths.
+func localModules(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read module file")
+	}
+	defer f.Close()
+
+	paths := []string{}
+	// the directive of the block being parsed
+	block := ""
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields, err := moduleFields(s.Text())
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse %s", path)
+		}
+		directive := block
+		switch {
+		case len(fields) == 0:
+			continue
+		case block != "":
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		default:
+			directive, fields = fields[0], fields[1:]
+		}
+
+		if p, ok := localModule(directive, fields); ok {
+			paths = append(paths, p)
+		}
+	}
+	return paths, errors.Wrapf(s.Err(), "cannot read %s", path)
+}
+
+// localModule provides the local path of a use or replace directive.
+func localModule(directive string, args []string) (string, bool) {
+	switch directive {
+	case "use":
+		if len(args) == 1 {
+			return args[0], true
+		}
+	case "replace":
+		// module [version] => path, a local path has no version
+		if n := len(args); n >= 3 && args[n-2] == "=>" && isLocalPath(args[n-1]) {
+			return args[n-1], true
+		}
+	}
+	return "", false
+}
+
+func isLocalPath(p string) bool {
+	return filepath.IsAbs(p) || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../") ||
+		strings.HasPrefix(p, `.\`) || strings.HasPrefix(p, `..\`) || p == "." || p == ".."
+}
+
+// moduleFields splits the line into its fields, dropping the comment and
+// unquoting the quoted fields.
+func moduleFields(line string) ([]string, error) {
+	fields := []string{}
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if strings.HasPrefix(line, "//") {
+			break
+		}
+		if line[0] == '"' || line[0] == '`' {
+			end := closingQuote(line)
+			if end < 0 {
+				return nil, errors.Errorf("unterminated quoted string %s", line)
+			}
+			f, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+			line = line[end+1:]
+			continue
+		}
+		n := strings.IndexAny(line, " \t")
+		if n < 0 {
+			n = len(line)
+		}
+		fields = append(fields, line[:n])
+		line = line[n:]
+	}
+	return fields, nil
+}
+
+// closingQuote provides the index of the quote closing the quoted string
+// the line starts with, or -1.
+func closingQuote(line string) int {
+	for i := 1; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && line[0] == '"':
+			i++
+		case line[i] == line[0]:
+			return i
+		}
+	}
+	return -1
+}
+
+// discoverModuleRoots rereads the local module directories when the go.work
+// or go.mod file in the base path changed.
+func (d *Daemon) discoverModuleRoots() {
+	if !d.moduleRoots {
+		return
+	}
+	stamp := ""
+	for _, name := range moduleFiles {
+		if info, err := os.Stat(filepath.Join(d.BasePath, name)); err == nil {
+			stamp += fmt.Sprintf("%s %d %d;", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	if stamp == d.modStamp {
+		return
+	}
+	d.modStamp = stamp
+
+	roots, err := ModuleRoots(d.BasePath)
+	if err != nil {
+		fmt.Fprintln(d.logOut, err)
+		d.emitError(err)
+	}
+	if equal(roots, d.modRoots) {
+		return
+	}
+	d.modRoots = roots
+	d.rootsChanged = true
+	fmt.Fprintf(d.logOut, "watching the local modules %s\n", strings.Join(roots, ", "))
+}
+
+// adoptRoots takes the files of the newly watched base paths into the previous
+// snapshot as they are, and forgets the files of the base paths no longer
+// watched, so that a change of the base paths is not reported as created and
+// deleted files.
+func adoptRoots(prev, next Snapshot, roots []string) {
+	prevRoots, watched := map[string]bool{}, map[string]bool{}
+	for _, f := range prev {
+		prevRoots[f.Root] = true
+	}
+	for _, r := range roots {
+		watched[r] = true
+	}
+	for p, f := range prev {
+		if !watched[f.Root] {
+			delete(prev, p)
+		}
+	}
+	for p, f := range next {
+		if !prevRoots[f.Root] {
+			prev[p] = f
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package daemon_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestModuleRoots(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		goMod   string
+		goWork  string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "no module files",
+			want: []string{},
+		},
+		{
+			name: "replace directives",
+			goMod: `module example.com/service
+
+require example.com/lib v1.0.0
+
+replace example.com/lib => ../lib // local checkout
+replace example.com/remote => example.com/fork v1.2.0
+replace (
+	example.com/tools v0.1.0 => "./tools"
+	// example.com/old => ../old
+)
+`,
+			want: []string{"../lib", "tools"},
+		},
+		{
+			name: "use directives",
+			goWork: `go 1.18
+
+use ./service
+use (
+	.
+	../lib
+)
+`,
+			goMod: "module example.com/service\n\nreplace example.com/lib => ../lib\n",
+			want:  []string{"service", ".", "../lib"},
+		},
+		{
+			name:    "unterminated quote",
+			goMod:   "replace example.com/lib => \"../lib\n",
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for name, content := range map[string]string{"go.mod": tt.goMod, "go.work": tt.goWork} {
+				if content == "" {
+					continue
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := daemon.ModuleRoots(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ModuleRoots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := []string{}
+			for _, p := range tt.want {
+				want = append(want, filepath.Join(dir, p))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ModuleRoots() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDaemon_Watch_moduleRoots(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	service := filepath.Join(tmp, "service")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(service, "main.go"), "package main\n")
+	write(filepath.Join(service, "go.mod"), "module example.com/service\n\nreplace example.com/lib => ../lib\n")
+	write(filepath.Join(tmp, "lib", "lib.go"), "package lib\n")
+	write(filepath.Join(tmp, "tools", "tools.go"), "package tools\n")
+
+	w := watch(t, daemon.WithBasePath(service), daemon.WithModuleRoots(true))
+
+	// the replaced module is watched
+	write(filepath.Join(tmp, "lib", "lib.go"), "package lib\n\nconst Version = 2\n")
+	w.waitScan()
+	// the module used by go.work is watched once go.work is created,
+	// without reporting its files as created
+	write(filepath.Join(service, "go.work"), "go 1.18\n\nuse (\n\t.\n\t../tools\n)\n")
+	w.waitScan()
+	write(filepath.Join(tmp, "tools", "tools.go"), "package tools\n\nconst Version = 2\n")
+	w.waitScan()
+
+	want := []event.Change{
+		{Path: filepath.Join(tmp, "lib", "lib.go"), Kind: event.Modified, Root: filepath.Join(tmp, "lib")},
+		{Path: filepath.Join(tmp, "tools", "tools.go"), Kind: event.Modified, Root: filepath.Join(tmp, "tools")},
+	}
+	if changes := w.stop(); !reflect.DeepEqual(changes, want) {
+		t.Errorf("Daemon.Watch() reported %v, want %v", changes, want)
+	}
+}
@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"hash/crc64"
+	"io"
+	"os"
+)
+
+// DefaultMaxHashSize is the size of the biggest file hashed, when
+// no other size is given.
+const DefaultMaxHashSize = 16 << 20
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// hashFile provides the checksum of the content of the file. The checksum
+// detects changes, it is not meant to be cryptographically secure.
+func hashFile(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := crc64.New(crcTable)
+	if _, err := io.Copy(h, f); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+// hashFiles sets the hashes of the files of the next snapshot which are
+// not bigger than the max size. The hash of a file which did not change
+// size nor modification time is taken over from the previous snapshot,
+// so that only new and touched files are read. Files which cannot be read
+// are left without a hash.
+func hashFiles(prev, next Snapshot, max int64) {
+	for p, f := range next {
+		if f.Dir || f.Size > max {
+			continue
+		}
+		if old, ok := prev[p]; ok && old.Hash != 0 && old.Size == f.Size && old.ModTime.Equal(f.ModTime) {
+			f.Hash = old.Hash
+		} else if h, err := hashFile(p); err == nil {
+			f.Hash = h
+		}
+		next[p] = f
+	}
+}
@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// allows for file systems keeping modification times with a coarse precision
+const mtimeSlack = time.Second
+
+// runWindow is the time when the command was running. The end is zero
+// while it is still running.
+type runWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+func (w runWindow) contains(t time.Time) bool {
+	return !t.Before(w.start.Add(-mtimeSlack)) && (w.end.IsZero() || !t.After(w.end))
+}
+
+// markRunStarted records the start of a run, for recognising the outputs
+// written by the command.
+func (d *Daemon) markRunStarted(start time.Time) {
+	d.stateMux.Lock()
+	d.runWindows = append(d.runWindows, runWindow{start: start})
+	d.stateMux.Unlock()
+}
+
+// markRunFinished records the end of the run started at start.
+func (d *Daemon) markRunFinished(start time.Time) {
+	d.stateMux.Lock()
+	defer d.stateMux.Unlock()
+	for i := range d.runWindows {
+		if d.runWindows[i].start.Equal(start) {
+			d.runWindows[i].end = time.Now()
+		}
+	}
+}
+
+// takeRunWindows provides the runs since the previous call, keeping only
+// those still running.
+func (d *Daemon) takeRunWindows() []runWindow {
+	d.stateMux.Lock()
+	defer d.stateMux.Unlock()
+
+	windows := d.runWindows
+	d.runWindows = nil
+	for _, w := range windows {
+		if w.end.IsZero() {
+			d.runWindows = append(d.runWindows, w)
+		}
+	}
+	return windows
+}
+
+// isOutput reports whether the path matches any of the output globs. The
+// globs are matched against the path relative to its base path and against
+// the file name.
+func (d *Daemon) isOutput(root, path string) bool {
+	if root == "" {
+		root = d.BasePath
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	for _, g := range d.outputs {
+		if ok, _ := filepath.Match(g, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dropOutputs removes the changes of the outputs written by the command.
+// A created or modified output is dropped when it was modified while
+// the command was running, a deleted one when the command ran since
+// the previous scan.
+func (d *Daemon) dropOutputs(changes []event.Change, next Snapshot, windows []runWindow) []event.Change {
+	if len(d.outputs) == 0 || len(windows) == 0 {
+		return changes
+	}
+
+	kept := changes[:0]
+	for _, c := range changes {
+		deleted := c.Kind == event.Deleted || c.Kind == event.DirDeleted
+		if d.isOutput(c.Root, c.Path) && (deleted || inWindows(windows, next[c.Path].ModTime)) {
+			d.debugf("ignoring %s %s by the command\n", c.Path, c.Kind)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+func inWindows(windows []runWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestDaemon_Watch_outputs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		outputs  []string
+		wantLoop bool
+	}{
+		{name: "output ignored", outputs: []string{"gen/*.go"}},
+		{name: "output by name ignored", outputs: []string{"zz_generated.go"}},
+		{name: "not an output", outputs: []string{"*.pb.go"}, wantLoop: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if err := os.Mkdir(filepath.Join(dir, "gen"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			mux := &sync.Mutex{}
+			runs := 0
+			d := daemon.New(
+				daemon.WithBasePath(dir),
+				daemon.WithCommand("touch "+filepath.Join(dir, "gen", "zz_generated.go")),
+				daemon.WithFrequency(1),
+				daemon.WithOutputs(tt.outputs),
+				daemon.WithLogWriter(ioutil.Discard),
+				daemon.WithEventHandler(event.HandlerFunc(func(e event.Event) {
+					if e.Type == event.RunStarted {
+						mux.Lock()
+						runs++
+						mux.Unlock()
+					}
+				})),
+			)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+			defer cancel()
+			go func() {
+				if err := d.Trigger(ctx, nil); err != nil {
+					t.Errorf("Daemon.Trigger() error = %s", err)
+				}
+			}()
+			d.Watch(ctx, make(chan os.Signal))
+
+			mux.Lock()
+			defer mux.Unlock()
+			if (runs > 1) != tt.wantLoop {
+				t.Errorf("Daemon.Watch() ran the command %d times, want loop %v", runs, tt.wantLoop)
+			}
+		})
+	}
+}
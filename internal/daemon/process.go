@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// how long a process gets to exit after being asked to stop before it is killed
+const stopTimeout = 5 * time.Second
+
+// how much of the output of the last run is kept
+const maxRunLog = 1 << 20
+
+// process is a started command.
+type process struct {
+	cmd *exec.Cmd
+	// closed when the daemon stops the process
+	stopping chan struct{}
+	// closed when the process has exited
+	done chan struct{}
+	// the captured output of the run, if any
+	output io.Closer
+}
+
+// exited releases the resources of the process once it has been waited for.
+func (p *process) exited() {
+	if p.output != nil {
+		//nolint:errcheck
+		p.output.Close()
+	}
+	close(p.done)
+}
+
+func (p *process) stopped() bool {
+	select {
+	case <-p.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// stop asks the process to terminate and kills it if it does not exit in time.
+func (p *process) stop() {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	close(p.stopping)
+	//nolint:errcheck
+	terminate(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(stopTimeout):
+		//nolint:errcheck
+		kill(p.cmd)
+		<-p.done
+	}
+}
+
+// startProcess starts the command in its own process group, so that it can
+// be stopped together with its children. The output is written to the daemon
+// output, kept as the log of the last run and captured to the output log,
+// if any. The caller is expected to wait for the process and call exited.
+func (d *Daemon) startProcess(cmdParts []string) (*process, error) {
+	log := newTailBuffer(maxRunLog)
+	stdout := []io.Writer{d.logOut, log}
+	stderr := []io.Writer{d.errOut, log}
+
+	var output io.WriteCloser
+	if d.outputLog != nil {
+		var err error
+		if output, err = d.outputLog.Open(d.Rule); err != nil {
+			fmt.Fprintf(d.logOut, "ERROR: %s\n", err)
+		} else {
+			stdout = append(stdout, output)
+			stderr = append(stderr, output)
+		}
+	}
+
+	cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
+	cmd.Stdout = io.MultiWriter(stdout...)
+	cmd.Stderr = io.MultiWriter(stderr...)
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		if output != nil {
+			//nolint:errcheck
+			output.Close()
+		}
+		return nil, err
+	}
+
+	p := &process{cmd: cmd, stopping: make(chan struct{}), done: make(chan struct{}), output: output}
+	d.procMux.Lock()
+	d.proc = p
+	d.lastLog = log
+	d.procMux.Unlock()
+
+	return p, nil
+}
+
+// stopProcess stops the running process, if any.
+func (d *Daemon) stopProcess() {
+	d.procMux.Lock()
+	p := d.proc
+	d.proc = nil
+	d.procMux.Unlock()
+
+	if p != nil {
+		p.stop()
+	}
+}
+
+// LastLog provides the output of the last (or currently running) run.
+func (d *Daemon) LastLog() string {
+	d.procMux.Lock()
+	defer d.procMux.Unlock()
+
+	if d.lastLog == nil {
+		return ""
+	}
+	return d.lastLog.String()
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mux *sync.Mutex
+	max int
+	buf []byte
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{mux: &sync.Mutex{}, max: max}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return string(b.buf)
+}
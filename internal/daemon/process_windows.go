@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package daemon
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminate(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
+
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,136 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// defaults used when the probe does not set the timeout or interval
+const (
+	defaultProbeTimeout  = 30 * time.Second
+	defaultProbeInterval = 500 * time.Millisecond
+)
+
+// Probe checks whether a process started in the restart mode is ready.
+// One of TCP, HTTP or Command is expected to be set.
+type Probe struct {
+	// TCP is an address which must accept connections, eg localhost:8080.
+	TCP string `json:"tcp,omitempty"`
+	// HTTP is a URL which must respond to GET with a 2xx status.
+	HTTP string `json:"http,omitempty"`
+	// Command must exit with 0.
+	Command string `json:"command,omitempty"`
+	// Timeout is how long to wait for the process to become ready,
+	// 30s by default.
+	Timeout time.Duration `json:"timeout_ns"`
+	// Interval between the checks, 500ms by default.
+	Interval time.Duration `json:"interval_ns"`
+}
+
+// Check runs the check once.
+func (p *Probe) Check(ctx context.Context) error {
+	switch {
+	case p.TCP != "":
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", p.TCP)
+		if err != nil {
+			return errors.Wrapf(err, "cannot connect to %s", p.TCP)
+		}
+		return conn.Close()
+	case p.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.HTTP, nil)
+		if err != nil {
+			return errors.Wrap(err, "invalid readiness URL")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return errors.Wrapf(err, "cannot get %s", p.HTTP)
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return errors.Errorf("%s responded with %s", p.HTTP, resp.Status)
+		}
+		return nil
+	case p.Command != "":
+		parts := strings.Split(p.Command, " ")
+		return exec.CommandContext(ctx, parts[0], parts[1:]...).Run()
+	default:
+		return nil
+	}
+}
+
+// wait repeats the check until it passes, the timeout expires
+// or the process exits.
+func (p *Probe) wait(proc *process) error {
+	timeout, interval := p.Timeout, p.Interval
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var err error
+	for {
+		if err = p.Check(ctx); err == nil {
+			return nil
+		}
+		select {
+		case <-proc.done:
+			return errors.New("process exited before becoming ready")
+		case <-ctx.Done():
+			return errors.Wrap(err, "readiness timed out")
+		case <-time.After(interval):
+		}
+	}
+}
+
+// awaitReadiness reports the process as starting and then as ready
+// or failed, depending on the outcome of the readiness check. Nothing
+// is reported once the process is superseded by a newer run.
+func (d *Daemon) awaitReadiness(proc *process, cmdParts []string, start time.Time) {
+	d.setReadiness(cmdParts, start, event.Starting)
+
+	d.cfgMux.RLock()
+	probe := d.Readiness
+	d.cfgMux.RUnlock()
+
+	if probe != nil {
+		err := probe.wait(proc)
+		if proc.stopped() {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(d.logOut, "ERROR: %s\n", err)
+			d.emitError(err)
+			d.setReadiness(cmdParts, start, event.Failed)
+			return
+		}
+	}
+	fmt.Fprint(d.logOut, "command ready\n\n")
+	d.setReadiness(cmdParts, start, event.Ready)
+}
+
+func (d *Daemon) setReadiness(cmdParts []string, start time.Time, state event.State) {
+	d.stateMux.Lock()
+	if d.lastRun != nil && d.lastRun.Started.Equal(start) {
+		d.lastRun.State = state
+	} else {
+		d.lastRun = &RunStatus{Args: cmdParts, Started: start, State: state}
+	}
+	d.stateMux.Unlock()
+
+	d.emit(event.Event{
+		Type: event.Readiness,
+		Run:  &event.Run{Args: cmdParts, Restart: true, State: state},
+	})
+}
@@ -0,0 +1,166 @@
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/metrics"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestProbe_Check(t *testing.T) {
+	t.Parallel()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listening := l.Addr().String()
+	defer l.Close()
+	l2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l2.Addr().String()
+	l2.Close()
+
+	tests := []struct {
+		name    string
+		probe   daemon.Probe
+		wantErr bool
+	}{
+		{name: "tcp - listening", probe: daemon.Probe{TCP: listening}},
+		{name: "tcp - closed", probe: daemon.Probe{TCP: closed}, wantErr: true},
+		{name: "http - 2xx", probe: daemon.Probe{HTTP: ok.URL}},
+		{name: "http - 5xx", probe: daemon.Probe{HTTP: failing.URL}, wantErr: true},
+		{name: "command - success", probe: daemon.Probe{Command: "true"}},
+		{name: "command - failure", probe: daemon.Probe{Command: "false"}, wantErr: true},
+		{name: "no check", probe: daemon.Probe{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.probe.Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Probe.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDaemon_Watch_readiness(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		probe daemon.Probe
+		// a second run restarts the process while the first one is starting
+		restarted   bool
+		want        []event.State
+		wantMetrics string
+	}{
+		{
+			name:        "ready",
+			probe:       daemon.Probe{Command: "true"},
+			want:        []event.State{event.Starting, event.Ready},
+			wantMetrics: `gofileswatcher_runs_total{result="success"} 1`,
+		},
+		{
+			name:        "failed",
+			probe:       daemon.Probe{Command: "false", Timeout: 300 * time.Millisecond, Interval: 50 * time.Millisecond},
+			want:        []event.State{event.Starting, event.Failed},
+			wantMetrics: `gofileswatcher_runs_total{result="failure"} 1`,
+		},
+		{
+			name:        "superseded",
+			probe:       daemon.Probe{Command: "sleep 0.5"},
+			restarted:   true,
+			want:        []event.State{event.Starting, event.Starting, event.Ready},
+			wantMetrics: `gofileswatcher_runs_total{result="success"} 1`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := &sync.Mutex{}
+			states := []event.State{}
+			collector := metrics.New("poll")
+			d := daemon.New(
+				daemon.WithBasePath("fixtures/basepath/subdir1"),
+				daemon.WithCommand("sleep 30"),
+				daemon.WithFrequency(60),
+				daemon.WithRestart(true),
+				daemon.WithReadiness(&tt.probe),
+				daemon.WithLogWriter(ioutil.Discard),
+				daemon.WithEventHandler(collector),
+				daemon.WithEventHandler(event.HandlerFunc(func(e event.Event) {
+					if e.Type == event.Readiness {
+						mux.Lock()
+						states = append(states, e.Run.State)
+						mux.Unlock()
+					}
+				})),
+			)
+			ctx, cancel := context.WithCancel(context.Background())
+			watchDone := make(chan struct{})
+			go func() {
+				d.Watch(ctx, make(chan os.Signal))
+				close(watchDone)
+			}()
+			defer func() {
+				cancel()
+				<-watchDone
+			}()
+
+			if err := d.Trigger(ctx, nil); err != nil {
+				t.Fatalf("Daemon.Trigger() error = %s", err)
+			}
+			var second time.Time
+			if tt.restarted {
+				time.Sleep(200 * time.Millisecond)
+				second = time.Now()
+				if err := d.Trigger(ctx, nil); err != nil {
+					t.Fatalf("Daemon.Trigger() error = %s", err)
+				}
+			}
+			time.Sleep(1500 * time.Millisecond)
+
+			mux.Lock()
+			got := append([]event.State{}, states...)
+			mux.Unlock()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Daemon.Watch() reported the states %v, want %v", got, tt.want)
+			}
+			st := d.Status()
+			if st.LastRun == nil || st.LastRun.State != tt.want[len(tt.want)-1] || st.LastRun.Started.Before(second) {
+				t.Errorf("Daemon.Status() last run = %+v, want %s started after %s",
+					st.LastRun, tt.want[len(tt.want)-1], second)
+			}
+			out := &bytes.Buffer{}
+			collector.Write(out)
+			if !strings.Contains(out.String(), tt.wantMetrics+"\n") {
+				t.Errorf("Collector.Write() missing %q in\n%s", tt.wantMetrics, out)
+			}
+		})
+	}
+}
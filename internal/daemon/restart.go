@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// restartCommand stops the running process, if any, and starts a new one.
+// It does not wait for the new process to finish. Must be called with
+// the cmdMux held.
+func (d *Daemon) restartCommand(cmdParts []string) error {
+	d.stopProcess()
+
+	d.emit(event.Event{Type: event.RunStarted, Run: &event.Run{Args: cmdParts, Restart: true}})
+	start := time.Now()
+	d.markRunStarted(start)
+
+	p, err := d.startProcess(cmdParts)
+	if err != nil {
+		run := &event.Run{Args: cmdParts, Restart: true, ExitCode: -1}
+		d.setLastRun(start, run)
+		d.emit(event.Event{Type: event.RunFinished, Run: run})
+		d.setReadiness(cmdParts, start, event.Failed)
+		d.markRunFinished(start)
+		return errors.Wrap(err, "cannot start command")
+	}
+	go d.awaitReadiness(p, cmdParts, start)
+
+	go func() {
+		err := p.cmd.Wait()
+		d.markRunFinished(start)
+		run := &event.Run{
+			Args:     cmdParts,
+			Restart:  true,
+			ExitCode: exitCode(p.cmd),
+			Duration: time.Since(start),
+			Stopped:  p.stopped(),
+		}
+		d.setLastRun(start, run)
+		d.emit(event.Event{Type: event.RunFinished, Run: run})
+		if err != nil && !run.Stopped {
+			fmt.Fprintf(d.logOut, "ERROR: %s\n", errors.Wrap(err, "command exited"))
+		}
+		p.exited()
+	}()
+
+	return nil
+}
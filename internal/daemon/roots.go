@@ -0,0 +1,56 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// roots provides the watched base paths: BasePath followed by BasePaths and
+// the discovered local modules, without the duplicates and the base paths
+// within another one, which are walked through as part of it.
+func (d *Daemon) roots() []string {
+	all := append([]string{d.BasePath}, d.BasePaths...)
+	all = append(all, d.modRoots...)
+	abs := make([]string, len(all))
+	for i, r := range all {
+		abs[i] = resolve(r)
+	}
+
+	roots := []string{}
+	for i, r := range all {
+		nested := false
+		for j := range all {
+			if i == j {
+				continue
+			}
+			// of the same roots the first one is kept
+			if (abs[i] == abs[j] && j < i) || (abs[i] != abs[j] && within(abs[j], abs[i])) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			roots = append(roots, r)
+		}
+	}
+	return roots
+}
+
+// rootsKey identifies the watched base paths in the persisted snapshot.
+func (d *Daemon) rootsKey() string {
+	return strings.Join(d.roots(), string(os.PathListSeparator))
+}
+
+// resolve provides the absolute path with the symlinks resolved, where
+// possible.
+func resolve(path string) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,74 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+)
+
+func TestDaemon_CollectFiles_basePaths(t *testing.T) {
+	t.Parallel()
+
+	tmp := t.TempDir()
+	service := filepath.Join(tmp, "service")
+	lib := filepath.Join(tmp, "lib")
+	for _, f := range []string{
+		filepath.Join(service, "main.go"),
+		filepath.Join(service, "internal", "api.go"),
+		filepath.Join(lib, "lib.go"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(f, []byte("package main\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		basePaths []string
+		want      []string
+	}{
+		{
+			name:      "single",
+			basePaths: []string{lib},
+			want:      []string{"lib.go in " + lib},
+		},
+		{
+			name:      "several",
+			basePaths: []string{service, lib},
+			want:      []string{"api.go in " + service, "lib.go in " + lib, "main.go in " + service},
+		},
+		{
+			name:      "overlapping",
+			basePaths: []string{filepath.Join(service, "internal"), lib, service, lib + string(filepath.Separator)},
+			want:      []string{"api.go in " + service, "lib.go in " + lib, "main.go in " + service},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			files, err := daemon.New(daemon.WithBasePaths(tt.basePaths)).CollectFiles(context.Background())
+			if err != nil {
+				t.Fatalf("Daemon.CollectFiles() error = %s", err)
+			}
+			got := []string{}
+			for _, f := range files {
+				got = append(got, f.Name+" in "+f.Root)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Daemon.CollectFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// how often the snapshot is saved while watching
+const snapshotInterval = time.Minute
+
+// version of the snapshot file format
+const snapshotVersion = 2
+
+// Snapshot is the state of the watched files, keyed by their path.
+type Snapshot map[string]FileInfo
+
+// NewSnapshot provides the snapshot of the files.
+func NewSnapshot(files []FileInfo) Snapshot {
+	s := make(Snapshot, len(files))
+	for _, f := range files {
+		s[f.Path] = f
+	}
+	return s
+}
+
+// Diff provides the changes turning the snapshot into the next one,
+// ordered by path. A file of the same size with a different modification
+// time is only modified when the hashes of its content, if known, differ.
+// An unmodified file with different attributes has its attributes changed.
+// A deleted and a created file with the same device and inode are paired
+// into a rename, when no other deleted or created file shares them, eg
+// a hard link. Directories are only created, deleted and renamed.
+func (s Snapshot) Diff(next Snapshot) []event.Change {
+	changes := []event.Change{}
+	// the paths of the created and the deleted files by their identity
+	created, deleted := map[fileID][]string{}, map[fileID][]string{}
+	for p, f := range next {
+		old, ok := s[p]
+		switch {
+		case !ok:
+			if f.Ino != 0 {
+				id := fileID{f.Dev, f.Ino}
+				created[id] = append(created[id], p)
+				continue
+			}
+			changes = append(changes, change(f, kind(f, event.Created, event.DirCreated)))
+		case old.Dir != f.Dir:
+			changes = append(changes,
+				change(old, kind(old, event.Deleted, event.DirDeleted)),
+				change(f, kind(f, event.Created, event.DirCreated)))
+		case f.Dir:
+		case old.Size != f.Size:
+			changes = append(changes, change(f, event.Modified))
+		case !old.ModTime.Equal(f.ModTime) && (old.Hash == 0 || f.Hash == 0 || old.Hash != f.Hash):
+			changes = append(changes, change(f, event.Modified))
+		case old.Mode != f.Mode || old.UID != f.UID || old.GID != f.GID || old.Xattrs != f.Xattrs:
+			changes = append(changes, change(f, event.AttributesChanged))
+		}
+	}
+	for p, f := range s {
+		if _, ok := next[p]; ok {
+			continue
+		}
+		if f.Ino != 0 {
+			id := fileID{f.Dev, f.Ino}
+			deleted[id] = append(deleted[id], p)
+			continue
+		}
+		changes = append(changes, change(f, kind(f, event.Deleted, event.DirDeleted)))
+	}
+	for id, paths := range deleted {
+		if news := created[id]; len(paths) == 1 && len(news) == 1 {
+			delete(created, id)
+			c := change(next[news[0]], kind(s[paths[0]], event.Renamed, event.DirRenamed))
+			c.OldPath = paths[0]
+			changes = append(changes, c)
+			continue
+		}
+		for _, p := range paths {
+			changes = append(changes, change(s[p], kind(s[p], event.Deleted, event.DirDeleted)))
+		}
+	}
+	for _, paths := range created {
+		for _, p := range paths {
+			changes = append(changes, change(next[p], kind(next[p], event.Created, event.DirCreated)))
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// change provides the change of the file.
+func change(f FileInfo, k event.Kind) event.Change {
+	return event.Change{Path: f.Path, Kind: k, Root: f.Root}
+}
+
+// kind provides the kind of the change of a file, or of a directory.
+func kind(f FileInfo, file, dir event.Kind) event.Kind {
+	if f.Dir {
+		return dir
+	}
+	return file
+}
+
+// fileID identifies a file on a device.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// SnapshotKey identifies what a persisted snapshot recorded: the watched base
+// paths, which files were watched and how they were tracked. A snapshot saved
+// with a different key is discarded, as it would report changes which did not
+// happen, eg of the attributes of every file or of the files of an extension
+// no longer watched.
+type SnapshotKey struct {
+	BasePath       string   `json:"base_path"`
+	Extension      string   `json:"extension,omitempty"`
+	Excluded       []string `json:"excluded,omitempty"`
+	EditorPatterns []string `json:"editor_patterns,omitempty"`
+	Attributes     bool     `json:"attributes,omitempty"`
+	Xattrs         bool     `json:"xattrs,omitempty"`
+	Directories    bool     `json:"directories,omitempty"`
+	MaxHashSize    int64    `json:"max_hash_size,omitempty"`
+	FollowSymlinks bool     `json:"follow_symlinks,omitempty"`
+	SymlinkRoots   []string `json:"symlink_roots,omitempty"`
+	ModuleRoots    bool     `json:"module_roots,omitempty"`
+	Embeds         bool     `json:"embeds,omitempty"`
+	GOOS           string   `json:"goos,omitempty"`
+	GOARCH         string   `json:"goarch,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+}
+
+func (k SnapshotKey) equal(o SnapshotKey) bool {
+	return k.BasePath == o.BasePath && k.Extension == o.Extension && equal(k.Excluded, o.Excluded) &&
+		equal(k.EditorPatterns, o.EditorPatterns) && k.Attributes == o.Attributes && k.Xattrs == o.Xattrs &&
+		k.Directories == o.Directories && k.MaxHashSize == o.MaxHashSize &&
+		k.FollowSymlinks == o.FollowSymlinks && equal(k.SymlinkRoots, o.SymlinkRoots) &&
+		k.ModuleRoots == o.ModuleRoots && k.Embeds == o.Embeds &&
+		k.GOOS == o.GOOS && k.GOARCH == o.GOARCH && equal(k.Tags, o.Tags)
+}
+
+type snapshotFile struct {
+	Version int `json:"version"`
+	SnapshotKey
+	Saved time.Time  `json:"saved"`
+	Files []FileInfo `json:"files"`
+}
+
+// LoadSnapshot reads the snapshot saved at path with the key. It provides nil,
+// when there is no snapshot or it was saved with a different key or by an
+// incompatible version.
+func LoadSnapshot(path string, key SnapshotKey) (Snapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the snapshot")
+	}
+
+	sf := snapshotFile{}
+	if err := json.Unmarshal(b, &sf); err != nil {
+		return nil, errors.Wrapf(err, "cannot decode the snapshot %s", path)
+	}
+	if sf.Version != snapshotVersion || !sf.SnapshotKey.equal(key) {
+		return nil, nil
+	}
+	return NewSnapshot(sf.Files), nil
+}
+
+// Save writes the snapshot with its key to path. The file is replaced
+// atomically, so that it is not left half written.
+func (s Snapshot) Save(path string, key SnapshotKey) error {
+	sf := snapshotFile{
+		Version:     snapshotVersion,
+		SnapshotKey: key,
+		Saved:       time.Now(),
+		Files:       make([]FileInfo, 0, len(s)),
+	}
+	for _, f := range s {
+		sf.Files = append(sf.Files, f)
+	}
+	sort.Slice(sf.Files, func(i, j int) bool { return sf.Files[i].Path < sf.Files[j].Path })
+
+	b, err := json.Marshal(sf)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode the snapshot")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.Wrap(err, "cannot create the snapshot directory")
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0o644); err != nil {
+		return errors.Wrap(err, "cannot write the snapshot")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "cannot replace the snapshot")
+}
+
+// loadSnapshot restores the snapshot saved by the previous run of the daemon,
+// so that the first scan reports the changes made meanwhile.
+func (d *Daemon) loadSnapshot() {
+	if d.stateFile == "" {
+		return
+	}
+	s, err := LoadSnapshot(d.stateFile, d.snapshotKey())
+	if err != nil {
+		fmt.Fprintf(d.logOut, "ERROR: %s\n", err)
+		d.emitError(err)
+		return
+	}
+	d.snapshot = s
+	d.restored = s != nil
+}
+
+// snapshotKey provides the key of the persisted snapshot.
+func (d *Daemon) snapshotKey() SnapshotKey {
+	key := d.tracking()
+	key.BasePath = d.rootsKey()
+	return key
+}
+
+// tracking provides the configuration the snapshot depends on, with the
+// configured base paths. A change of it starts from a new snapshot.
+func (d *Daemon) tracking() SnapshotKey {
+	key := SnapshotKey{
+		BasePath:       strings.Join(append([]string{d.BasePath}, d.BasePaths...), string(os.PathListSeparator)),
+		Extension:      d.Extention,
+		Excluded:       d.Excluded,
+		EditorPatterns: d.editorPatterns,
+		Attributes:     d.attributes,
+		Xattrs:         d.xattrs,
+		Directories:    d.directories,
+		MaxHashSize:    d.maxHashSize,
+		FollowSymlinks: d.followSymlinks,
+		SymlinkRoots:   d.symlinkRoots,
+		ModuleRoots:    d.moduleRoots,
+		Embeds:         d.embedAssets,
+	}
+	if d.build != nil {
+		key.GOOS, key.GOARCH, key.Tags = d.build.GOOS, d.build.GOARCH, d.build.BuildTags
+	}
+	return key
+}
+
+// saveSnapshot saves the current snapshot, if it is persisted.
+func (d *Daemon) saveSnapshot() {
+	if d.stateFile == "" || d.snapshot == nil {
+		return
+	}
+	d.snapshotSaved = time.Now()
+	if err := d.snapshot.Save(d.stateFile, d.snapshotKey()); err != nil {
+		fmt.Fprintf(d.logOut, "ERROR: %s\n", err)
+		d.emitError(err)
+	}
+}
+
+// detectChanges compares the scanned files with the previous scan, reporting
+// the changes and requesting a run. The first scan only records the files,
+// unless the snapshot was restored, when the changes are reported and run
+// only if catching up is on. It provides after how long the files are to be
+// checked again, when changes are held back, or zero.
+func (d *Daemon) detectChanges(files []FileInfo) time.Duration {
+	// the runs since the previous scan
+	windows := d.takeRunWindows()
+
+	next := NewSnapshot(files)
+	if d.maxHashSize > 0 {
+		hashFiles(d.snapshot, next, d.maxHashSize)
+	}
+	if d.build != nil {
+		matchBuild(d.build, d.snapshot, next)
+	}
+	prev, restored := d.snapshot, d.restored
+	d.snapshot, d.restored = next, false
+	embedsChanged := d.embedsChanged
+	d.embedsChanged = nil
+	if prev == nil {
+		d.rootsChanged = false
+		d.saveSnapshotPeriodically()
+		return 0
+	}
+	if d.rootsChanged {
+		d.rootsChanged = false
+		adoptRoots(prev, next, d.roots())
+	}
+	adoptEmbeds(prev, next, embedsChanged)
+
+	changes := d.dropIrrelevant(d.dropOutputs(prev.Diff(next), next, windows), prev, next)
+	changes, deleting := d.holdDeleted(changes, prev, next)
+	changes, writing := d.holdUnstable(changes, prev, next)
+	d.saveSnapshotPeriodically()
+
+	recheck := time.Duration(0)
+	switch {
+	case deleting:
+		recheck = atomicSaveGrace
+	case writing:
+		recheck = d.stableFor
+	}
+	if len(changes) == 0 {
+		return recheck
+	}
+	if restored {
+		if !d.catchUp {
+			fmt.Fprintf(d.logOut, "%d files changed while the watcher was not running\n", len(changes))
+			return recheck
+		}
+		fmt.Fprintf(d.logOut, "catching up with %d files changed while the watcher was not running\n", len(changes))
+	}
+
+	for i := range changes {
+		logChange(d.logOut, changes[i])
+		d.emit(event.Event{Type: event.ChangeDetected, Change: &changes[i]})
+	}
+	d.requestRun()
+	return recheck
+}
+
+// logChange prints the detected change.
+func logChange(w io.Writer, c event.Change) {
+	switch c.Kind {
+	case event.Renamed:
+		fmt.Fprintf(w, "File %s has been renamed to %s\n", c.OldPath, c.Path)
+	case event.DirRenamed:
+		fmt.Fprintf(w, "Directory %s has been renamed to %s\n", c.OldPath, c.Path)
+	case event.DirCreated:
+		fmt.Fprintf(w, "Directory %s has been created\n", c.Path)
+	case event.DirDeleted:
+		fmt.Fprintf(w, "Directory %s has been deleted\n", c.Path)
+	default:
+		fmt.Fprintf(w, "File %s has been %s\n", c.Path, c.Kind)
+	}
+}
+
+func (d *Daemon) saveSnapshotPeriodically() {
+	if time.Since(d.snapshotSaved) >= snapshotInterval {
+		d.saveSnapshot()
+	}
+}
+
+// requestRun asks for a run of the command without blocking. Requests made
+// while one is already pending are coalesced.
+func (d *Daemon) requestRun() {
+	select {
+	case d.runReq <- struct{}{}:
+	default:
+	}
+}
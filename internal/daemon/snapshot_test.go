@@ -0,0 +1,272 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestSnapshot_Diff(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	prev := daemon.NewSnapshot([]daemon.FileInfo{
+		{Path: "a.go", ModTime: now, Size: 1},
+		{Path: "b.go", ModTime: now, Size: 1},
+		{Path: "c.go", ModTime: now, Size: 1},
+		{Path: "d.go", ModTime: now, Size: 1},
+		{Path: "f.go", ModTime: now, Size: 1, Hash: 1},
+		{Path: "g.go", ModTime: now, Size: 1, Hash: 1},
+		{Path: "h.go", ModTime: now, Size: 1, Mode: 0o644},
+		{Path: "i.go", ModTime: now, Size: 1, UID: 1, Xattrs: 1},
+		{Path: "old.go", ModTime: now, Size: 1, Dev: 1, Ino: 7},
+		{Path: "other.go", ModTime: now, Size: 1, Dev: 1, Ino: 8},
+		{Path: "src.go", ModTime: now, Size: 1, Dev: 1, Ino: 10},
+		{Path: "moved.go", ModTime: now, Size: 1, Dev: 1, Ino: 11},
+		{Path: "pkg", ModTime: now, Dir: true},
+		{Path: "gone", Dir: true},
+		{Path: "olddir", Dir: true, Dev: 1, Ino: 9},
+		{Path: "x", ModTime: now, Size: 1},
+	})
+	next := daemon.NewSnapshot([]daemon.FileInfo{
+		{Path: "a.go", ModTime: now, Size: 1},
+		{Path: "b.go", ModTime: now.Add(time.Second), Size: 1},
+		{Path: "c.go", ModTime: now, Size: 2},
+		{Path: "e.go", ModTime: now, Size: 1},
+		{Path: "f.go", ModTime: now.Add(time.Second), Size: 1, Hash: 1},
+		{Path: "g.go", ModTime: now.Add(time.Second), Size: 1, Hash: 2},
+		{Path: "h.go", ModTime: now, Size: 1, Mode: 0o755},
+		{Path: "i.go", ModTime: now, Size: 1, UID: 1, Xattrs: 2},
+		{Path: "new.go", ModTime: now, Size: 1, Dev: 1, Ino: 7},
+		{Path: "other_dev.go", ModTime: now, Size: 1, Dev: 2, Ino: 8},
+		// hard links of a kept file and of a moved one
+		{Path: "src.go", ModTime: now, Size: 1, Dev: 1, Ino: 10},
+		{Path: "link1.go", ModTime: now, Size: 1, Dev: 1, Ino: 10},
+		{Path: "link2.go", ModTime: now, Size: 1, Dev: 1, Ino: 10},
+		{Path: "moved1.go", ModTime: now, Size: 1, Dev: 1, Ino: 11},
+		{Path: "moved2.go", ModTime: now, Size: 1, Dev: 1, Ino: 11},
+		{Path: "pkg", ModTime: now.Add(time.Second), Dir: true},
+		{Path: "api", Dir: true},
+		{Path: "newdir", Dir: true, Dev: 1, Ino: 9},
+		{Path: "x", Dir: true},
+	})
+
+	want := []event.Change{
+		{Path: "api", Kind: event.DirCreated},
+		{Path: "b.go", Kind: event.Modified},
+		{Path: "c.go", Kind: event.Modified},
+		{Path: "d.go", Kind: event.Deleted},
+		{Path: "e.go", Kind: event.Created},
+		{Path: "g.go", Kind: event.Modified},
+		{Path: "gone", Kind: event.DirDeleted},
+		{Path: "h.go", Kind: event.AttributesChanged},
+		{Path: "i.go", Kind: event.AttributesChanged},
+		{Path: "link1.go", Kind: event.Created},
+		{Path: "link2.go", Kind: event.Created},
+		{Path: "moved.go", Kind: event.Deleted},
+		{Path: "moved1.go", Kind: event.Created},
+		{Path: "moved2.go", Kind: event.Created},
+		{Path: "new.go", Kind: event.Renamed, OldPath: "old.go"},
+		{Path: "newdir", Kind: event.DirRenamed, OldPath: "olddir"},
+		{Path: "other.go", Kind: event.Deleted},
+		{Path: "other_dev.go", Kind: event.Created},
+		{Path: "x", Kind: event.Deleted},
+		{Path: "x", Kind: event.DirCreated},
+	}
+	if got := prev.Diff(next); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot.Diff() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state", "snapshot.json")
+	s := daemon.NewSnapshot([]daemon.FileInfo{{Path: "a.go", Name: "a.go", ModTime: time.Unix(1, 0).UTC(), Size: 3}})
+	key := daemon.SnapshotKey{
+		BasePath:       ".",
+		Extension:      ".go",
+		Excluded:       []string{"vendor"},
+		EditorPatterns: []string{"*~"},
+		Attributes:     true,
+		MaxHashSize:    1024,
+		SymlinkRoots:   []string{"/shared"},
+		GOOS:           "linux",
+		GOARCH:         "amd64",
+		Tags:           []string{"dev"},
+	}
+	if err := s.Save(path, key); err != nil {
+		t.Fatalf("Snapshot.Save() error = %s", err)
+	}
+	with := func(f func(k *daemon.SnapshotKey)) daemon.SnapshotKey {
+		k := key
+		f(&k)
+		return k
+	}
+
+	tests := []struct {
+		name string
+		path string
+		key  daemon.SnapshotKey
+		want daemon.Snapshot
+	}{
+		{name: "saved", path: path, key: key, want: s},
+		{name: "other base path", path: path, key: with(func(k *daemon.SnapshotKey) { k.BasePath = "other" })},
+		{name: "other extension", path: path, key: with(func(k *daemon.SnapshotKey) { k.Extension = ".ts" })},
+		{name: "other excluded", path: path, key: with(func(k *daemon.SnapshotKey) { k.Excluded = nil })},
+		{name: "other editor patterns", path: path, key: with(func(k *daemon.SnapshotKey) { k.EditorPatterns = nil })},
+		{name: "no attributes", path: path, key: with(func(k *daemon.SnapshotKey) { k.Attributes = false })},
+		{name: "xattrs", path: path, key: with(func(k *daemon.SnapshotKey) { k.Xattrs = true })},
+		{name: "directories", path: path, key: with(func(k *daemon.SnapshotKey) { k.Directories = true })},
+		{name: "other hash size", path: path, key: with(func(k *daemon.SnapshotKey) { k.MaxHashSize = 0 })},
+		{name: "symlinks followed", path: path, key: with(func(k *daemon.SnapshotKey) { k.FollowSymlinks = true })},
+		{name: "other symlink roots", path: path, key: with(func(k *daemon.SnapshotKey) { k.SymlinkRoots = nil })},
+		{name: "module roots", path: path, key: with(func(k *daemon.SnapshotKey) { k.ModuleRoots = true })},
+		{name: "embeds", path: path, key: with(func(k *daemon.SnapshotKey) { k.Embeds = true })},
+		{name: "other GOOS", path: path, key: with(func(k *daemon.SnapshotKey) { k.GOOS = "windows" })},
+		{name: "other GOARCH", path: path, key: with(func(k *daemon.SnapshotKey) { k.GOARCH = "arm64" })},
+		{name: "other tags", path: path, key: with(func(k *daemon.SnapshotKey) { k.Tags = nil })},
+		{name: "missing", path: path + ".missing", key: key},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := daemon.LoadSnapshot(tt.path, tt.key)
+			if err != nil {
+				t.Fatalf("LoadSnapshot() error = %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadSnapshot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_Watch_catchUp(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(file, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stateFile := filepath.Join(dir, "state", "snapshot.json")
+
+	watch := func(catchUp bool) []event.Event {
+		events := make(chan event.Event, 100)
+		d := daemon.New(
+			daemon.WithBasePath(dir),
+			daemon.WithCommand("true"),
+			daemon.WithFrequency(60),
+			daemon.WithStateFile(stateFile),
+			daemon.WithCatchUp(catchUp),
+			daemon.WithLogWriter(ioutil.Discard),
+			daemon.WithEventHandler(event.HandlerFunc(func(e event.Event) { events <- e })),
+		)
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		d.Watch(ctx, make(chan os.Signal))
+		close(events)
+
+		got := []event.Event{}
+		for e := range events {
+			got = append(got, e)
+		}
+		return got
+	}
+
+	if events := watch(true); hasEvent(events, event.ChangeDetected) {
+		t.Errorf("Daemon.Watch() detected changes without a saved snapshot")
+	}
+	if err := ioutil.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if events := watch(false); hasEvent(events, event.RunStarted) {
+		t.Errorf("Daemon.Watch() caught up when not asked to")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "new.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	events := watch(true)
+	if !hasEvent(events, event.ChangeDetected) || !hasEvent(events, event.RunStarted) {
+		t.Errorf("Daemon.Watch() did not catch up, got %v", events)
+	}
+}
+
+func hasEvent(events []event.Event, typ event.Type) bool {
+	for _, e := range events {
+		if e.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDaemon_Watch_directories(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		directories bool
+		want        []event.Change
+	}{
+		{
+			name:        "tracked",
+			directories: true,
+			want: []event.Change{
+				{Path: "api", Kind: event.DirCreated},
+				{Path: "lib", Kind: event.DirRenamed, OldPath: "pkg"},
+				{Path: "old", Kind: event.DirDeleted},
+			},
+		},
+		{name: "not tracked", want: []event.Change{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for _, d := range []string{"pkg", "old", filepath.Join(".git", "objects")} {
+				if err := os.MkdirAll(filepath.Join(dir, d), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := watch(t, daemon.WithBasePath(dir), daemon.WithDirectories(tt.directories))
+
+			if err := os.Mkdir(filepath.Join(dir, "api"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(filepath.Join(dir, ".git", "refs"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(filepath.Join(dir, "pkg"), filepath.Join(dir, "lib")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Remove(filepath.Join(dir, "old")); err != nil {
+				t.Fatal(err)
+			}
+			w.waitScan()
+
+			want := []event.Change{}
+			for _, c := range tt.want {
+				c.Path, c.Root = filepath.Join(dir, c.Path), dir
+				if c.OldPath != "" {
+					c.OldPath = filepath.Join(dir, c.OldPath)
+				}
+				want = append(want, c)
+			}
+			if changes := w.stop(); !reflect.DeepEqual(changes, want) {
+				t.Errorf("Daemon.Watch() reported %v, want %v", changes, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// DefaultMaxStableWait is how long a change waits for its file to become
+// stable, when no other maximum is given.
+const DefaultMaxStableWait = 10 * time.Second
+
+// holdUnstable holds back the changes of the files which are still being
+// written: modified within the stable duration or, where it can be found
+// out, opened for writing. The held files keep their previous state in the
+// next snapshot, so that the following scan reports them again. A change
+// held for longer than the maximum wait is reported anyway. It provides
+// the changes to report and whether any change is held.
+func (d *Daemon) holdUnstable(changes []event.Change, prev, next Snapshot) ([]event.Change, bool) {
+	if d.stableFor <= 0 {
+		return changes, false
+	}
+
+	now := time.Now()
+	candidates := map[string]bool{}
+	for _, c := range changes {
+		if isFileWrite(c.Kind) {
+			candidates[absPath(c.Path)] = true
+		}
+	}
+	writing := openForWriting(candidates)
+
+	ready := changes[:0]
+	held := false
+	for _, c := range changes {
+		since, waiting := d.unstableSince[c.Path]
+		unstable := isFileWrite(c.Kind) &&
+			(now.Sub(next[c.Path].ModTime) < d.stableFor || writing[absPath(c.Path)])
+		if !unstable || (waiting && now.Sub(since) >= d.maxStableWait) {
+			delete(d.unstableSince, c.Path)
+			ready = append(ready, c)
+			continue
+		}
+
+		if !waiting {
+			d.unstableSince[c.Path] = now
+		}
+		d.debugf("waiting for %s to be written\n", c.Path)
+		if old, ok := prev[c.Path]; ok {
+			next[c.Path] = old
+		} else {
+			delete(next, c.Path)
+		}
+		if c.OldPath != "" {
+			next[c.OldPath] = prev[c.OldPath]
+		}
+		held = true
+	}
+	return ready, held
+}
+
+// isFileWrite reports whether the change is a write to a file.
+func isFileWrite(k event.Kind) bool {
+	switch k {
+	case event.Deleted, event.DirCreated, event.DirDeleted, event.DirRenamed:
+		return false
+	}
+	return true
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
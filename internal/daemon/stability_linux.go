@@ -0,0 +1,69 @@
+//go:build linux
+// +build linux
+
+package daemon
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// openForWriting finds out which of the files (absolute paths) are opened
+// for writing by any process, which file descriptors can be read from /proc.
+func openForWriting(files map[string]bool) map[string]bool {
+	writing := map[string]bool{}
+	if len(files) == 0 {
+		return writing
+	}
+
+	procs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return writing
+	}
+	for _, p := range procs {
+		if _, err := strconv.Atoi(p.Name()); err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !files[target] {
+				continue
+			}
+			if fdWritable(filepath.Join("/proc", p.Name(), "fdinfo", fd.Name())) {
+				writing[target] = true
+			}
+		}
+	}
+	return writing
+}
+
+// fdWritable reads the access mode from the flags of the fdinfo file.
+func fdWritable(fdinfo string) bool {
+	f, err := os.Open(fdinfo)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if !strings.HasPrefix(sc.Text(), "flags:") {
+			continue
+		}
+		flags, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(sc.Text(), "flags:")), 8, 64)
+		if err != nil {
+			return false
+		}
+		return flags&int64(os.O_WRONLY|os.O_RDWR) != 0
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package daemon_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestDaemon_Watch_writeStability(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS != "linux" {
+		t.Skip("files opened for writing are only detected on linux")
+	}
+
+	tests := []struct {
+		name    string
+		maxWait time.Duration
+		// when the file being written is closed
+		closeAfter time.Duration
+		// when the change is expected to be reported
+		wantAfter time.Duration
+	}{
+		{
+			name:       "reported once closed",
+			maxWait:    10 * time.Second,
+			closeAfter: 1800 * time.Millisecond,
+			wantAfter:  1800 * time.Millisecond,
+		},
+		{
+			name:       "reported after max wait",
+			maxWait:    500 * time.Millisecond,
+			closeAfter: 4 * time.Second,
+			wantAfter:  1500 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			mux := &sync.Mutex{}
+			var detected time.Time
+			start := time.Now()
+			w := watch(t,
+				daemon.WithBasePath(dir),
+				daemon.WithWriteStability(100*time.Millisecond, tt.maxWait),
+				daemon.WithEventHandler(event.HandlerFunc(func(e event.Event) {
+					mux.Lock()
+					defer mux.Unlock()
+					if e.Type == event.ChangeDetected && detected.IsZero() {
+						detected = time.Now()
+					}
+				})),
+			)
+			reported := func() time.Time {
+				mux.Lock()
+				defer mux.Unlock()
+				return detected
+			}
+
+			// the file is created after the initial scan
+			f, err := os.Create(filepath.Join(dir, "big.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if _, err := f.WriteString("package big\n"); err != nil {
+				t.Fatal(err)
+			}
+			time.AfterFunc(tt.closeAfter, func() { f.Close() })
+			// the scans go on while the change is held back
+			for reported().IsZero() && time.Since(start) < tt.wantAfter+time.Second {
+				w.waitScan()
+			}
+
+			if reported().IsZero() {
+				t.Fatal("Daemon.Watch() did not report the change")
+			}
+			if got := reported().Sub(start); got < tt.wantAfter || got > tt.wantAfter+time.Second {
+				t.Errorf("Daemon.Watch() reported the change after %s, want about %s", got, tt.wantAfter)
+			}
+		})
+	}
+}
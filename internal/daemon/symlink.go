@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dirKey identifies a walked directory by its device and inode or, where
+// the inode is not available, by its path with the symlinks resolved.
+type dirKey struct {
+	id   fileID
+	path string
+}
+
+func newDirKey(path string, info os.FileInfo) dirKey {
+	dev, ino := fileIdentity(info)
+	if ino != 0 {
+		return dirKey{id: fileID{dev, ino}}
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		real = path
+	}
+	abs, _ := filepath.Abs(real)
+	return dirKey{path: abs}
+}
+
+// symlinked is the information of a symlink target named as the symlink.
+type symlinked struct {
+	os.FileInfo
+	name string
+}
+
+func (s symlinked) Name() string {
+	return s.name
+}
+
+// walk walks through the tree at root like filepath.Walk, providing the paths
+// under shown. When following symlinks, a symlinked file is provided with the
+// information of its target and a symlinked directory is walked through, unless
+// it was already walked through (which breaks symlink loops) or its target is
+// outside of the allowed roots.
+func (d *Daemon) walk(root, shown string, visited map[dirKey]bool, fn filepath.WalkFunc) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		real := path
+		if shown != root {
+			rel, _ := filepath.Rel(root, path)
+			path = filepath.Join(shown, rel)
+			if rel == "." && info != nil {
+				info = symlinked{info, filepath.Base(shown)}
+			}
+		}
+		if err != nil || !d.followSymlinks || info.Mode()&os.ModeSymlink == 0 {
+			if err == nil && info.IsDir() {
+				visited[newDirKey(real, info)] = true
+			}
+			return fn(path, info, err)
+		}
+
+		target, ok := d.symlinkTarget(path)
+		if !ok {
+			return nil
+		}
+		tinfo, err := os.Stat(target)
+		if err != nil {
+			return fn(path, nil, err)
+		}
+		if !tinfo.IsDir() {
+			return fn(path, symlinked{tinfo, filepath.Base(path)}, nil)
+		}
+		if visited[newDirKey(target, tinfo)] {
+			d.debugf("not following %s, %s already walked through\n", path, target)
+			return nil
+		}
+		return d.walk(target, path, visited, fn)
+	})
+}
+
+// symlinkTarget resolves the symlink, checking that its target is within
+// the base paths or one of the allowed roots, if any are given.
+func (d *Daemon) symlinkTarget(path string) (string, bool) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// broken symlink
+		d.debugf("not following %s: %s\n", path, err)
+		return "", false
+	}
+	if len(d.symlinkRoots) == 0 {
+		return target, true
+	}
+
+	abs, _ := filepath.Abs(target)
+	for _, r := range append(d.roots(), d.symlinkRoots...) {
+		if within(r, abs) {
+			return target, true
+		}
+	}
+	d.debugf("not following %s, %s is outside of the allowed roots\n", path, target)
+	return "", false
+}
+
+// within reports whether the absolute path is the root or below it.
+func within(root, path string) bool {
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		root = real
+	}
+	root, _ = filepath.Abs(root)
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,95 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+)
+
+func TestDaemon_CollectFiles_symlinks(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs privileges on windows")
+	}
+
+	tmp := t.TempDir()
+	base := filepath.Join(tmp, "base")
+	shared := filepath.Join(tmp, "shared")
+	for _, d := range []string{filepath.Join(base, "sub"), shared} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{filepath.Join(base, "main.go"), filepath.Join(shared, "config.go")} {
+		if err := ioutil.WriteFile(f, []byte("package main\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(base, "shared"):         shared,
+		filepath.Join(base, "sub", "loop"):    base,
+		filepath.Join(base, "alias.go"):       filepath.Join(base, "main.go"),
+		filepath.Join(base, "broken.go"):      filepath.Join(tmp, "missing.go"),
+		filepath.Join(shared, "back_to_base"): base,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		follow bool
+		roots  []string
+		want   []string
+	}{
+		{
+			name: "not followed",
+			want: []string{"alias.go", "broken.go", "main.go"},
+		},
+		{
+			name:   "followed",
+			follow: true,
+			want:   []string{"alias.go", "main.go", "shared/config.go"},
+		},
+		{
+			name:   "followed within roots",
+			follow: true,
+			roots:  []string{filepath.Join(tmp, "other")},
+			want:   []string{"alias.go", "main.go"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := daemon.New(
+				daemon.WithBasePath(base),
+				daemon.WithSymlinks(tt.follow, tt.roots),
+				daemon.WithLogWriter(ioutil.Discard),
+			)
+			files, err := d.CollectFiles(context.Background())
+			if err != nil {
+				t.Fatalf("Daemon.CollectFiles() error = %s", err)
+			}
+			got := []string{}
+			for _, f := range files {
+				rel, _ := filepath.Rel(base, f.Path)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Daemon.CollectFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,35 +13,87 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
 )
 
-// FileInfo captures file path, name and modification time.
+// FileInfo captures file path, name, size and modification time.
 // This information is required for the watch functionality.
 type FileInfo struct {
-	Path    string
-	Name    string
-	ModTime time.Time
+	Path string `json:"path"`
+	// Root is the base path the file was found under.
+	Root    string    `json:"root,omitempty"`
+	Name    string    `json:"name"`
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	// Hash of the content, zero when the content is not hashed.
+	Hash uint64 `json:"hash,omitempty"`
+	// Dev and Ino identify the file on the platforms which have them,
+	// they are zero otherwise.
+	Dev uint64 `json:"dev,omitempty"`
+	Ino uint64 `json:"ino,omitempty"`
+	// Mode, Uid and Gid are set when the attributes are tracked,
+	// Xattrs is a hash of the extended attributes, when they are tracked.
+	Mode   os.FileMode `json:"mode,omitempty"`
+	UID    uint32      `json:"uid,omitempty"`
+	GID    uint32      `json:"gid,omitempty"`
+	Xattrs uint64      `json:"xattrs,omitempty"`
+	// Dir is set for a directory, which has no size nor attributes.
+	Dir bool `json:"dir,omitempty"`
+	// OutOfBuild is set for a Go file not part of the build, when the build
+	// constraints are evaluated.
+	OutOfBuild bool `json:"out_of_build,omitempty"`
 }
 
-// Watch watches for changes in files at regular intervals
+// Watch watches for changes in files at regular intervals. It returns
+// after the context is cancelled, a signal is received or Quit is called,
+// once the running command has been stopped. Cancelling the context quits
+// the daemon, like Quit.
 func (d *Daemon) Watch(ctx context.Context, sigCh chan os.Signal) {
-	fmt.Print("\nStarting the watcher daemon ⌚ 👀 ... \n\n")
-	cmdParts := strings.Split(d.Command, " ")
+	fmt.Fprint(d.logOut, "\nStarting the watcher daemon ⌚ 👀 ... \n\n")
 
 	// use when a change is detected to avoid processing further files
-	doneCh := make(chan struct{})
+	doneCh := d.doneChan
 	// use when a change is detected, after successfully running the command,
 	// to cancel already created goroutines
 	cancelCh := make(chan struct{})
 
 	// Starts a gouroutine checking on the run outcome, running the command as required
-	d.runOutcomeChecker(cmdParts, sigCh, doneCh, cancelCh)
+	d.runOutcomeChecker(sigCh, doneCh, cancelCh)
+
+	// the initial scan records the files or, with a restored snapshot,
+	// detects the changes made while the daemon was not running
+	d.discoverModuleRoots()
+	d.loadSnapshot()
+	// fires when the held back changes are to be checked again
+	recheck := d.check(ctx)
+
+	// the context of the latest check, cancelled by a failed run
+	// or when the next check starts
+	cancel := context.CancelFunc(func() {})
+	checkContext := func() context.Context {
+		cancel()
+		ctxR, c := context.WithCancel(ctx)
+		cancel = c
+		return ctxR
+	}
 
 	tick := time.NewTicker(d.frequency)
 	for {
-		ctxR, cancel := context.WithCancel(ctx)
 		select {
+		case <-ctx.Done():
+			cancel()
+			d.shutdown(tick)
+			return
+		case <-d.quitCh:
+			cancel()
+			d.shutdown(tick)
+			return
 		case <-tick.C:
+			if d.isPaused() {
+				continue
+			}
+			ctxR := checkContext()
+
 			// implementation 1
 			// d.walkThroughFiles(ctxR, doneCh)
 
@@ -50,37 +102,69 @@ func (d *Daemon) Watch(ctx context.Context, sigCh chan os.Signal) {
 			// d.processFiles(ctxR, files, doneCh)
 
 			// implementation 3
-			files, err := d.CollectFiles(ctxR)
-			if err != nil {
-				fmt.Println(err)
-				continue
+			// files, _ := d.scan(ctxR)
+			// d.processRecentChanges(ctxR, files, doneCh)
+
+			// implementation 4
+			recheck = d.check(ctxR)
+		case <-recheck:
+			recheck = nil
+			if !d.isPaused() {
+				recheck = d.check(checkContext())
 			}
-
-			//nolint:lll
-			// Creating a buffered channel will avoid leaking goroutines. This would  // happen if there are still running goroutines after one finds a change
-			// and sends to a done channel. If the done channel is not buffered, then
-			// some of the running gouroutines may also try to send to the done
-			// channel and would be blocked forever, ie would start leaking.
-			doneAllCh := make(chan struct{}, len(files))
-
-			go func(doneAllCh, doneCh chan struct{}) {
-				select {
-				case <-doneAllCh:
-					d.doneMux.Lock()
-					doneCh <- struct{}{}
-					d.doneMux.Unlock()
-				case <-time.After(d.frequency * 2):
-					return
-				}
-			}(doneAllCh, doneCh)
-
-			d.ProcessFilesInParallel(ctxR, files, doneAllCh)
+		case nd := <-d.reloadCh:
+			d.applyConfig(nd)
+			tick.Reset(d.frequency)
+			fmt.Fprint(d.logOut, "configuration reloaded\n\n")
 		case <-cancelCh:
 			cancel()
 		}
 	}
 }
 
+// check scans the files and detects the changes. It provides the channel
+// firing when the held back changes are to be checked again, or nil.
+func (d *Daemon) check(ctx context.Context) <-chan time.Time {
+	d.discoverModuleRoots()
+	files, err := d.scan(ctx)
+	if err != nil {
+		fmt.Fprintln(d.logOut, err)
+		return nil
+	}
+	if recheck := d.detectChanges(files); recheck > 0 {
+		return time.After(recheck)
+	}
+	return nil
+}
+
+// processRecentChanges requests a run when any of the files was modified
+// since the previous tick.
+//nolint:unused
+func (d *Daemon) processRecentChanges(ctx context.Context, files []FileInfo, doneCh chan struct{}) {
+	//nolint:lll
+	// Creating a buffered channel will avoid leaking goroutines. This would  // happen if there are still running goroutines after one finds a change
+	// and sends to a done channel. If the done channel is not buffered, then
+	// some of the running gouroutines may also try to send to the done
+	// channel and would be blocked forever, ie would start leaking.
+	doneAllCh := make(chan struct{}, len(files))
+
+	go func(doneAllCh, doneCh chan struct{}, frequency time.Duration) {
+		select {
+		case <-doneAllCh:
+			d.doneMux.Lock()
+			select {
+			case doneCh <- struct{}{}:
+			case <-d.quitCh:
+			}
+			d.doneMux.Unlock()
+		case <-time.After(frequency * 2):
+			return
+		}
+	}(doneAllCh, doneCh, d.frequency)
+
+	d.ProcessFilesInParallel(ctx, files, doneAllCh)
+}
+
 // CollectFiles checks if any watched file has changed.
 // The Walk function continues the walk while theere is no error and stops
 // when the filepath.WalkFunc exits with error.
@@ -89,15 +173,16 @@ func (d *Daemon) walkThroughFiles(ctx context.Context, doneCh chan struct{}) {
 	filepath.Walk(d.BasePath, func(path string, info os.FileInfo, err error) error {
 		if info.IsDir() ||
 			strings.HasPrefix(path, ".git") ||
-			(!info.IsDir() && filepath.Ext(path) != d.Extention) {
+			(!info.IsDir() && filepath.Ext(path) != d.Extention) ||
+			d.isEditorArtefact(info.Name()) {
 			return nil
 		}
 
-		fmt.Printf("FILE info:  %s\n", info.Name())
+		fmt.Fprintf(d.logOut, "FILE info:  %s\n", info.Name())
 
 		lastChecked := time.Now().Add(-d.frequency)
 		if info.ModTime().After(lastChecked) {
-			fmt.Printf("\tFile %s has changed\n", info.Name())
+			fmt.Fprintf(d.logOut, "\tFile %s has changed\n", info.Name())
 			// trigger running of the command
 			doneCh <- struct{}{}
 			// return any known error to stop walking through the dir content
@@ -107,54 +192,147 @@ func (d *Daemon) walkThroughFiles(ctx context.Context, doneCh chan struct{}) {
 	})
 }
 
+// scan collects the watched files and directories, emitting the scan
+// lifecycle events.
+func (d *Daemon) scan(ctx context.Context) ([]FileInfo, error) {
+	roots := d.roots()
+	if len(roots) == 1 {
+		roots = nil
+	}
+	d.emit(event.Event{Type: event.ScanStarted, Scan: &event.Scan{BasePath: d.BasePath, Roots: roots}})
+	start := time.Now()
+
+	files, err := d.collect(ctx, d.directories)
+	if err != nil {
+		d.emitError(err)
+		return nil, err
+	}
+
+	n := 0
+	for _, f := range files {
+		if !f.Dir {
+			n++
+		}
+	}
+	d.setFilesWatched(n)
+	d.emit(event.Event{
+		Type: event.ScanFinished,
+		Scan: &event.Scan{
+			BasePath: d.BasePath,
+			Roots:    roots,
+			Files:    n,
+			Duration: time.Since(start),
+		},
+	})
+	return files, nil
+}
+
 // CollectFiles checks if any watched file has changed
 func (d *Daemon) CollectFiles(ctx context.Context) ([]FileInfo, error) {
+	return d.collect(ctx, false)
+}
+
+// collect walks through the base paths collecting the watched files and,
+// with dirs, the directories below the base paths. The .git directories
+// are not walked through, symlinks are followed and the files embedded by
+// the Go files are added when configured.
+func (d *Daemon) collect(ctx context.Context, dirs bool) ([]FileInfo, error) {
 	var files []FileInfo
 
-	err := filepath.Walk(d.BasePath, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() ||
-			strings.HasPrefix(path, ".git") ||
-			(!info.IsDir() && filepath.Ext(path) != d.Extention) {
-			return err // this will be nil if there is no problem with the file
+	d.editorDirs = map[string]bool{}
+	visited := map[dirKey]bool{}
+	for _, root := range d.roots() {
+		if err := d.walk(root, root, visited, d.collector(ctx, root, dirs, &files)); err != nil {
+			return nil, errors.Wrapf(err, "error collecting files from %s", root)
 		}
+	}
+	if d.embedAssets {
+		files = d.addEmbedded(ctx, files)
+	}
 
-		if len(d.Excluded) != 0 {
-			isExcl, err := d.IsExcluded(ctx, path, info.Name())
-			if err != nil {
-				panic(errors.Wrap(err, "cannot proccess exclusion of files"))
+	return files, nil
+}
+
+// collector provides the function collecting the files under the root.
+func (d *Daemon) collector(ctx context.Context, root string, dirs bool, files *[]FileInfo) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		// files removed during the walk, eg temporary files, are skipped
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" && path != root {
+				return filepath.SkipDir
 			}
-			if isExcl {
-				return nil
+			if dirs && path != root && !d.excluded(ctx, path, info.Name()) {
+				dev, ino := fileIdentity(info)
+				*files = append(*files, FileInfo{
+					Path: path, Root: root, Name: info.Name(), ModTime: info.ModTime(), Dir: true, Dev: dev, Ino: ino,
+				})
 			}
+			return nil
+		}
+		// editor temporary files are matched whatever their extension,
+		// as they tell a deletion may be part of a save
+		if d.isEditorArtefact(info.Name()) {
+			d.editorDirs[filepath.Dir(path)] = true
+			return nil
+		}
+		if strings.HasPrefix(path, ".git") ||
+			filepath.Ext(path) != d.Extention ||
+			d.excluded(ctx, path, info.Name()) {
+			return nil
 		}
 
-		files = append(files, FileInfo{
-			Path:    path,
-			Name:    info.Name(),
-			ModTime: info.ModTime(),
-		})
+		*files = append(*files, d.newFileInfo(path, root, info))
 		//fmt.Printf("FILE info:  %s - %s\n", path, info.Name())
 
 		return nil
-	})
-	if err != nil {
-		return nil, errors.Wrapf(err, "error collecting files from %s", d.BasePath)
 	}
+}
 
-	return files, nil
+// newFileInfo provides the information of the watched file.
+func (d *Daemon) newFileInfo(path, root string, info os.FileInfo) FileInfo {
+	dev, ino := fileIdentity(info)
+	fi := FileInfo{
+		Path:    path,
+		Root:    root,
+		Name:    info.Name(),
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Dev:     dev,
+		Ino:     ino,
+	}
+	d.setAttributes(&fi, info)
+	return fi
+}
+
+// excluded reports whether the path is excluded by the configuration.
+func (d *Daemon) excluded(ctx context.Context, path, name string) bool {
+	if len(d.Excluded) == 0 {
+		return false
+	}
+	isExcl, err := d.IsExcluded(ctx, path, name)
+	if err != nil {
+		panic(errors.Wrap(err, "cannot proccess exclusion of files"))
+	}
+	return isExcl
 }
 
 //nolint:unused
 func (d *Daemon) processFiles(ctx context.Context, files []FileInfo, doneCh chan struct{}) {
 
-	fmt.Println("GOT to processing ...")
+	fmt.Fprintln(d.logOut, "GOT to processing ...")
 
 	for _, f := range files {
 		time.Sleep(100 * time.Millisecond)
 
 		lastChecked := time.Now().Add(-d.frequency)
 		if f.ModTime.After(lastChecked) {
-			fmt.Printf("File %s has changed\n", f.Name)
+			fmt.Fprintf(d.logOut, "File %s has changed\n", f.Name)
 			doneCh <- struct{}{}
 			break
 		}
@@ -174,10 +352,10 @@ func (d *Daemon) ProcessFilesInParallel(ctx context.Context, files []FileInfo, d
 	// channel to continue looping.
 	// Note: I tried to use select default to continue the looping but that
 	// did not work.
-	fmt.Println("---------------")
+	d.debugf("---------------\n")
 LOOP:
 	for _, f := range files {
-		fmt.Printf("--> processing file %s\n", f.Name)
+		d.debugf("--> processing file %s\n", f.Name)
 
 		wg.Add(1)
 		go func(wg *sync.WaitGroup, f FileInfo, doneCh chan struct{}, stopCh chan struct{}) {
@@ -186,7 +364,11 @@ LOOP:
 
 			lastChecked := time.Now().Add(-d.frequency)
 			if f.ModTime.After(lastChecked) {
-				fmt.Printf("File %s has changed\n", f.Name)
+				fmt.Fprintf(d.logOut, "File %s has changed\n", f.Name)
+				d.emit(event.Event{
+					Type:   event.ChangeDetected,
+					Change: &event.Change{Path: f.Path, Kind: event.Modified},
+				})
 				stopCh <- struct{}{}
 				return
 			}
@@ -196,12 +378,12 @@ LOOP:
 		select {
 		case <-stopCh:
 			doneCh <- struct{}{}
-			fmt.Printf("\t--> finishing with file %s\n\n", f.Name)
+			d.debugf("\t--> finishing with file %s\n\n", f.Name)
 			break LOOP
 		case <-continueCh:
 		}
 	}
-	fmt.Println("---------------")
+	d.debugf("---------------\n")
 
 	wg.Wait()
 }
@@ -229,30 +411,124 @@ func (d *Daemon) IsExcluded(ctx context.Context, path, name string) (bool, error
 	return toExclude, nil
 }
 
-func (d *Daemon) runOutcomeChecker(cmdParts []string, sigCh chan os.Signal, doneCh, cancelCh chan struct{}) {
+func (d *Daemon) runOutcomeChecker(sigCh chan os.Signal, doneCh, cancelCh chan struct{}) {
 	go func() {
 		for {
 			select {
 			case <-sigCh:
-				fmt.Println("You interrupted me 👹!")
-				os.Exit(0)
+				fmt.Fprintln(d.logOut, "You interrupted me 👹!")
+				d.Quit()
+				return
+			case <-d.quitCh:
+				return
 			case <-doneCh:
-				d.cmdMux.Lock()
-
-				cmd := exec.Command(cmdParts[0], cmdParts[1:]...)
-				// these can be commented out if not needed
-				cmd.Stdout = os.Stdout
-				cmd.Stderr = os.Stderr
-				err := cmd.Run()
-				if err != nil {
-					fmt.Printf("ERROR: %s\n", errors.Wrap(err, "error occurred processing during file watch"))
-					cancelCh <- struct{}{}
-					d.cmdMux.Unlock()
-					continue
+				if !d.handleRun(cancelCh) {
+					return
+				}
+			case <-d.runReq:
+				if !d.handleRun(cancelCh) {
+					return
 				}
-				fmt.Print("command completed successfully\n\n")
-				d.cmdMux.Unlock()
 			}
 		}
 	}()
 }
+
+// handleRun runs the command, reporting the outcome. It provides false
+// when the daemon is quitting, without running the command when it was
+// requested before, but picked up after, the daemon started quitting.
+func (d *Daemon) handleRun(cancelCh chan struct{}) bool {
+	d.cmdMux.Lock()
+	if d.quitting() {
+		d.cmdMux.Unlock()
+		return false
+	}
+
+	restart := d.restartMode()
+	run := d.runCommand
+	if restart {
+		run = d.restartCommand
+	}
+	err := run(d.commandParts())
+	if d.quitting() {
+		d.cmdMux.Unlock()
+		return false
+	}
+	if err != nil {
+		err = errors.Wrap(err, "error occurred processing during file watch")
+		fmt.Fprintf(d.logOut, "ERROR: %s\n", err)
+		d.emitError(err)
+		d.cmdMux.Unlock()
+		select {
+		case cancelCh <- struct{}{}:
+		case <-d.quitCh:
+			return false
+		}
+		return true
+	}
+	if restart {
+		fmt.Fprint(d.logOut, "command restarted\n\n")
+	} else {
+		fmt.Fprint(d.logOut, "command completed successfully\n\n")
+	}
+	d.cmdMux.Unlock()
+	return true
+}
+
+// runCommand runs the command, emitting the run lifecycle events.
+// A run stopped by the daemon, eg when quitting, is not an error.
+func (d *Daemon) runCommand(cmdParts []string) error {
+	d.emit(event.Event{Type: event.RunStarted, Run: &event.Run{Args: cmdParts}})
+	start := time.Now()
+	d.markRunStarted(start)
+	defer d.markRunFinished(start)
+
+	p, err := d.startProcess(cmdParts)
+	if err != nil {
+		run := &event.Run{Args: cmdParts, ExitCode: -1}
+		d.setLastRun(start, run)
+		d.emit(event.Event{Type: event.RunFinished, Run: run})
+		return err
+	}
+	err = p.cmd.Wait()
+	p.exited()
+
+	run := &event.Run{
+		Args:     cmdParts,
+		ExitCode: exitCode(p.cmd),
+		Duration: time.Since(start),
+		Stopped:  p.stopped(),
+	}
+	d.setLastRun(start, run)
+	d.emit(event.Event{Type: event.RunFinished, Run: run})
+	if run.Stopped {
+		return nil
+	}
+	return err
+}
+
+// exitCode provides the exit code of a finished command or -1
+// if the command did not start or was terminated by a signal.
+func exitCode(cmd *exec.Cmd) int {
+	if cmd.ProcessState == nil {
+		return -1
+	}
+	return cmd.ProcessState.ExitCode()
+}
+
+// shutdown stops the ticker and the running command and saves the snapshot.
+// Quitting first keeps the pending run requests from starting another run.
+// The run in progress is stopped before waiting for the command mutex, so
+// that the wait does not last until the run finishes. Stopping again catches
+// a process (re)started meanwhile.
+func (d *Daemon) shutdown(tick *time.Ticker) {
+	tick.Stop()
+	fmt.Fprint(d.logOut, "stopping the watcher daemon ...\n")
+	d.Quit()
+	d.saveSnapshot()
+
+	d.stopProcess()
+	d.cmdMux.Lock()
+	d.stopProcess()
+	d.cmdMux.Unlock()
+}
@@ -450,6 +450,9 @@ func TestDaemon_ProcessFilesInParallel(t *testing.T) {
 				if err != nil {
 					t.Errorf("TestDaemon_ProcessFilesInParallel - %s", err)
 				}
+				if files, err = d.CollectFiles(tt.args.ctx); err != nil {
+					t.Errorf("TestDaemon_ProcessFilesInParallel - %s", err)
+				}
 			}
 			d.ProcessFilesInParallel(tt.args.ctx, files, tt.args.doneCh)
 		})
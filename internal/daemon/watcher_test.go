@@ -0,0 +1,138 @@
+package daemon_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// how long a scan is waited for
+const scanTimeout = 10 * time.Second
+
+// watcher runs Daemon.Watch in the background, recording the reported changes.
+type watcher struct {
+	t       *testing.T
+	mux     *sync.Mutex
+	changes []event.Change
+	// the number of the scans started and finished, and of the changes
+	// reported by the start of each scan
+	started  int
+	finished int
+	reported []int
+	scanned  chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// watch starts watching with the options, checking every second and running
+// true, and waits for the initial scan. Watching stops with the test.
+func watch(t *testing.T, ops ...daemon.Option) *watcher {
+	t.Helper()
+
+	w := &watcher{t: t, mux: &sync.Mutex{}, changes: []event.Change{}, scanned: make(chan struct{}, 1)}
+	ops = append([]daemon.Option{
+		daemon.WithCommand("true"),
+		daemon.WithFrequency(1),
+		daemon.WithLogWriter(ioutil.Discard),
+	}, ops...)
+	d := daemon.New(append(ops, daemon.WithEventHandler(event.HandlerFunc(w.handle)))...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel, w.done = cancel, make(chan struct{})
+	go func() {
+		d.Watch(ctx, make(chan os.Signal))
+		close(w.done)
+	}()
+	t.Cleanup(func() { w.stop() })
+
+	w.waitScans(1)
+	return w
+}
+
+func (w *watcher) handle(e event.Event) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	switch e.Type {
+	case event.ScanStarted:
+		w.started++
+		w.reported = append(w.reported, len(w.changes))
+	case event.ScanFinished:
+		w.finished++
+		select {
+		case w.scanned <- struct{}{}:
+		default:
+		}
+	case event.ChangeDetected:
+		w.changes = append(w.changes, *e.Change)
+	}
+}
+
+// waitScan waits for a scan started after the call to finish, so that
+// it sees the changes made before, and provides its number.
+func (w *watcher) waitScan() int {
+	w.t.Helper()
+
+	w.mux.Lock()
+	want := w.started + 1
+	w.mux.Unlock()
+	w.waitScans(want)
+	return want
+}
+
+// waitScans waits for the scans to have finished n times.
+func (w *watcher) waitScans(n int) {
+	w.t.Helper()
+
+	timeout := time.After(scanTimeout)
+	for {
+		w.mux.Lock()
+		finished := w.finished
+		w.mux.Unlock()
+		if finished >= n {
+			return
+		}
+		select {
+		case <-w.scanned:
+		case <-timeout:
+			w.t.Fatal("Daemon.Watch() did not scan the files")
+		}
+	}
+}
+
+// reportedBy waits for the changes seen by the nth scan to be compared,
+// which is done by the time the next scan starts, and provides the number
+// of the changes reported by then.
+func (w *watcher) reportedBy(n int) int {
+	w.t.Helper()
+
+	timeout := time.After(scanTimeout)
+	for {
+		w.mux.Lock()
+		if len(w.reported) > n {
+			defer w.mux.Unlock()
+			return w.reported[n]
+		}
+		w.mux.Unlock()
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-timeout:
+			w.t.Fatal("Daemon.Watch() did not scan the files")
+		}
+	}
+}
+
+// stop stops watching and provides the reported changes.
+func (w *watcher) stop() []event.Change {
+	w.cancel()
+	<-w.done
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.changes
+}
@@ -0,0 +1,48 @@
+//go:build linux
+// +build linux
+
+package daemon
+
+import (
+	"bytes"
+	"hash/crc64"
+	"sort"
+	"syscall"
+)
+
+// hashXattrs provides a hash of the names and values of the extended
+// attributes of the file, zero when it has none or they cannot be read.
+func hashXattrs(path string) uint64 {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return 0
+	}
+	buf := make([]byte, size)
+	if size, err = syscall.Listxattr(path, buf); err != nil {
+		return 0
+	}
+
+	names := []string{}
+	for _, n := range bytes.Split(buf[:size], []byte{0}) {
+		if len(n) != 0 {
+			names = append(names, string(n))
+		}
+	}
+	sort.Strings(names)
+
+	h := crc64.New(crcTable)
+	for _, n := range names {
+		h.Write([]byte(n))
+		h.Write([]byte{0})
+		vsize, err := syscall.Getxattr(path, n, nil)
+		if err != nil {
+			continue
+		}
+		val := make([]byte, vsize)
+		if vsize, err = syscall.Getxattr(path, n, val); err == nil {
+			h.Write(val[:vsize])
+		}
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
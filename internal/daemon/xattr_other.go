@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package daemon
+
+// hashXattrs does not read the extended attributes on this platform.
+func hashXattrs(path string) uint64 {
+	return 0
+}
@@ -0,0 +1,220 @@
+package history_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/history"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestRecorder_Handle(t *testing.T) {
+	t.Parallel()
+
+	store, err := history.Open(filepath.Join(t.TempDir(), "state", "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := "build output"
+	rec := history.NewRecorder(store, func() string { return output }, ioutil.Discard)
+
+	start := time.Now().Add(-time.Hour)
+	runs := []struct {
+		rule     string
+		changes  []string
+		exitCode int
+		restart  event.State
+	}{
+		{rule: "api", changes: []string{"cmd/main.go", "pkg/a.go"}, exitCode: 0},
+		{rule: "api", changes: []string{"pkg/b.go"}, exitCode: 1},
+		{rule: "web", restart: event.Failed},
+		{rule: "web", changes: []string{"web/index.go"}, restart: event.Ready},
+	}
+	for i, r := range runs {
+		started := start.Add(time.Duration(i) * time.Minute)
+		for _, c := range r.changes {
+			rec.Handle(event.Event{Type: event.ChangeDetected, Change: &event.Change{Path: c, Kind: event.Modified}})
+		}
+		rec.Handle(event.Event{
+			Type: event.RunStarted,
+			Rule: r.rule,
+			Time: started,
+			Run:  &event.Run{Args: []string{"go", "build"}},
+		})
+		finished := event.Event{Time: started.Add(2 * time.Second), Run: &event.Run{ExitCode: r.exitCode}}
+		if r.restart != "" {
+			rec.Handle(event.Event{Type: event.Readiness, Time: started, Run: &event.Run{State: event.Starting}})
+			finished.Type, finished.Run.State = event.Readiness, r.restart
+		} else {
+			finished.Type = event.RunFinished
+		}
+		rec.Handle(finished)
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(runs) {
+		t.Fatalf("Load() got %d records, want %d", len(records), len(runs))
+	}
+	if records[0].ID != 1 || records[0].Output != output || len(records[0].Triggers) != 2 {
+		t.Errorf("Load() got first record %+v", records[0])
+	}
+
+	tests := []struct {
+		name   string
+		filter history.Filter
+		want   []int
+	}{
+		{name: "all", want: []int{1, 2, 3, 4}},
+		{name: "failed", filter: history.Filter{FailedOnly: true}, want: []int{2, 3}},
+		{name: "rule", filter: history.Filter{Rule: "web"}, want: []int{3, 4}},
+		{name: "file", filter: history.Filter{File: "pkg/"}, want: []int{1, 2}},
+		{name: "since", filter: history.Filter{Since: start.Add(90 * time.Second)}, want: []int{3, 4}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := history.Select(records, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Select() got %d records, want %v", len(got), tt.want)
+			}
+			for i, r := range got {
+				if r.ID != tt.want[i] {
+					t.Errorf("Select() got record %d, want %d", r.ID, tt.want[i])
+				}
+			}
+		})
+	}
+
+	st := history.Summarize(records)
+	if st.Runs != 4 || st.Failed != 2 || st.AverageDuration != 2*time.Second {
+		t.Errorf("Summarize() got %+v", st)
+	}
+}
+
+func TestStore_Append_maxRecords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		maxRecords int
+		appended   int
+		want       int
+	}{
+		{name: "within the limit", maxRecords: 8, appended: 8, want: 8},
+		{name: "within the batch", maxRecords: 8, appended: 10, want: 10},
+		{name: "trimmed", maxRecords: 8, appended: 11, want: 8},
+		{name: "trimmed again", maxRecords: 8, appended: 21, want: 9},
+		{name: "unlimited", maxRecords: 0, appended: 21, want: 21},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := history.Open(filepath.Join(t.TempDir(), "history.jsonl"), history.WithMaxRecords(tt.maxRecords))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.appended; i++ {
+				if err := store.Append(history.Record{Rule: "api", ExitCode: i}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			records, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != tt.want {
+				t.Fatalf("got %d records, want %d", len(records), tt.want)
+			}
+			for i, r := range records {
+				if id := tt.appended - tt.want + i + 1; r.ExitCode != id-1 || r.ID != id {
+					t.Errorf("got record %d with ID %d, want %d with ID %d", r.ExitCode, r.ID, id-1, id)
+				}
+			}
+		})
+	}
+}
+
+func TestStore_Append_legacy(t *testing.T) {
+	t.Parallel()
+
+	// records written before the IDs were stored
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	legacy := ""
+	for i := 0; i < 4; i++ {
+		legacy += fmt.Sprintf("{\"rule\":\"api\",\"exit_code\":%d}\n", i)
+	}
+	if err := ioutil.WriteFile(path, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := history.Open(path, history.WithMaxRecords(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 4; i < 7; i++ {
+		if err := store.Append(history.Record{Rule: "api", ExitCode: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+	for i, r := range records {
+		if r.ExitCode != i+2 || r.ID != i+3 {
+			t.Errorf("got record %d with ID %d, want %d with ID %d", r.ExitCode, r.ID, i+2, i+3)
+		}
+	}
+}
+
+func TestStore_Append_concurrent(t *testing.T) {
+	t.Parallel()
+
+	// daemons sharing the store, each with its own Store
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	const stores, appended = 4, 10
+	errs := make(chan error, stores)
+	for i := 0; i < stores; i++ {
+		go func() {
+			store, err := history.Open(path, history.WithMaxRecords(stores*appended))
+			for j := 0; err == nil && j < appended; j++ {
+				err = store.Append(history.Record{Rule: "api"})
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < stores; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := history.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != stores*appended {
+		t.Fatalf("got %d records, want %d", len(records), stores*appended)
+	}
+	for i, r := range records {
+		if r.ID != i+1 {
+			t.Errorf("got record %d with ID %d", i+1, r.ID)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+package history
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock of the file at path, creating it if needed,
+// and provides the function releasing it.
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint:errcheck
+		f.Close()
+	}, nil
+}
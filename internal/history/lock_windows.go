@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package history
+
+// lockFile is not available on this platform, processes sharing the store
+// are not kept from appending at the same time.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
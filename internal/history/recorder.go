@@ -0,0 +1,87 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// how much of the output of a run is recorded
+const maxOutput = 16 * 1024
+
+// Recorder builds the records from the daemon events.
+type Recorder struct {
+	store *Store
+	// provides the output of the current run
+	output func() string
+	errOut io.Writer
+
+	mux     *sync.Mutex
+	pending []Trigger
+	current *Record
+}
+
+// NewRecorder is a constructor providing a Recorder appending to the store.
+// The output function provides the output of the run being recorded,
+// failures to record are reported to errOut.
+func NewRecorder(store *Store, output func() string, errOut io.Writer) *Recorder {
+	return &Recorder{
+		store:  store,
+		output: output,
+		errOut: errOut,
+		mux:    &sync.Mutex{},
+	}
+}
+
+// Handle records a run once it finishes, or, in the restart mode,
+// once it is ready or failed.
+func (rec *Recorder) Handle(e event.Event) {
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	switch e.Type {
+	case event.ChangeDetected:
+		rec.pending = append(rec.pending, Trigger{Path: e.Change.Path, Kind: string(e.Change.Kind)})
+	case event.RunStarted:
+		rec.current = &Record{
+			Rule:     e.Rule,
+			Args:     e.Run.Args,
+			Triggers: rec.pending,
+			Started:  e.Time,
+		}
+		rec.pending = nil
+	case event.Readiness:
+		if e.Run.State != event.Starting && rec.current != nil {
+			rec.current.State = string(e.Run.State)
+			rec.finish(e)
+		}
+	case event.RunFinished:
+		if !e.Run.Restart && rec.current != nil {
+			rec.current.ExitCode = e.Run.ExitCode
+			rec.finish(e)
+		}
+	}
+}
+
+func (rec *Recorder) finish(e event.Event) {
+	r := rec.current
+	rec.current = nil
+
+	r.Finished = e.Time
+	r.Output = tail(rec.output(), maxOutput)
+	if r.Triggers == nil {
+		r.Triggers = []Trigger{}
+	}
+	if err := rec.store.Append(*r); err != nil {
+		fmt.Fprintf(rec.errOut, "cannot record the run: %s\n", err)
+	}
+}
+
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[len(s)-max:]
+}
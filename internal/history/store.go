@@ -0,0 +1,361 @@
+// Package history records the runs of the command to a local store
+// and provides querying of the recorded runs.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Trigger is a file change which triggered a run.
+type Trigger struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// Record describes a single run.
+type Record struct {
+	// ID identifies the record, it increases with each record appended.
+	ID       int       `json:"id"`
+	Rule     string    `json:"rule"`
+	Args     []string  `json:"args"`
+	Triggers []Trigger `json:"triggers"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	ExitCode int       `json:"exit_code"`
+	// State is the readiness of the process in the restart mode.
+	State string `json:"state,omitempty"`
+	// Output is the end of the output of the run.
+	Output string `json:"output"`
+}
+
+// Failed reports whether the run failed.
+func (r Record) Failed() bool {
+	if r.State != "" {
+		return r.State != "ready"
+	}
+	return r.ExitCode != 0
+}
+
+// Duration of the run.
+func (r Record) Duration() time.Duration {
+	return r.Finished.Sub(r.Started)
+}
+
+// Store keeps the records in a file, one JSON object per line.
+type Store struct {
+	mux        *sync.Mutex
+	path       string
+	maxRecords int
+}
+
+// Option provides a way to customise the Store.
+type Option func(*Store)
+
+// StateDir provides the directory of the watcher in the user's state
+// directory ($XDG_STATE_HOME, ~/.local/state by default).
+func StateDir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "cannot find the state directory")
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "go-files-watcher"), nil
+}
+
+// DefaultPath provides the location of the store in the state directory.
+func DefaultPath() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// Open is a constructor providing a Store kept in the file at path.
+// The file and its directory are created by the first Append.
+func Open(path string, ops ...Option) (*Store, error) {
+	s := &Store{mux: &sync.Mutex{}, path: path, maxRecords: 1000}
+	for _, o := range ops {
+		o(s)
+	}
+	return s, nil
+}
+
+// WithMaxRecords allows to override how many of the latest records are kept
+// (1000 by default). The oldest records are removed in batches, a quarter
+// of the limit at a time, so the file is not rewritten on every run. Zero
+// keeps all of them.
+func WithMaxRecords(n int) Option {
+	return func(s *Store) {
+		s.maxRecords = n
+	}
+}
+
+// Append adds the record to the store, giving it the ID following the latest
+// one. Processes sharing the store take turns through a lock file next to it,
+// where the platform supports it.
+func (s *Store) Append(r Record) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return errors.Wrap(err, "cannot create the history directory")
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return errors.Wrap(err, "cannot lock the history")
+	}
+	defer unlock()
+
+	first, last, err := s.ids()
+	if err != nil {
+		return err
+	}
+	r.ID = last + 1
+	if first == 0 {
+		first = r.ID
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode the run")
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "cannot open the history")
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrap(err, "cannot write the history")
+	}
+
+	// the IDs are consecutive, so they tell the number of the records
+	if s.maxRecords > 0 && r.ID-first+1 > s.maxRecords+s.maxRecords/4 {
+		return s.trim()
+	}
+	return nil
+}
+
+// ids provides the IDs of the first and the latest record, reading only
+// the ends of the file when it can. Both are 0 for an empty store.
+func (s *Store) ids() (first, last int, err error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "cannot open the history")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "cannot read the history")
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, 0, nil
+	}
+	// a record is at most 4*maxOutput long once encoded
+	tail := make([]byte, min64(size, 4*maxOutput+64*1024))
+	if _, err := f.ReadAt(tail, size-int64(len(tail))); err != nil {
+		return 0, 0, errors.Wrap(err, "cannot read the history")
+	}
+	tail = bytes.TrimRight(tail, "\n")
+	r := Record{}
+	if err := json.Unmarshal(tail[bytes.LastIndexByte(tail, '\n')+1:], &r); err == nil && r.ID > 0 {
+		head, err := bufio.NewReader(io.NewSectionReader(f, 0, size)).ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return 0, 0, errors.Wrap(err, "cannot read the history")
+		}
+		// a record written before the IDs were stored is the first line
+		h := Record{ID: 1}
+		_ = json.Unmarshal(head, &h)
+		return h.ID, r.ID, nil
+	}
+
+	// the latest line has no ID or it is incomplete
+	lines, err := s.lines()
+	if err != nil {
+		return 0, 0, err
+	}
+	records := decode(lines)
+	if len(records) == 0 {
+		return 0, len(lines), nil
+	}
+	return records[0].ID, records[len(records)-1].ID, nil
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// trim keeps the latest records up to the limit. Records written before
+// the IDs were stored get theirs, so that they keep their IDs.
+func (s *Store) trim() error {
+	lines, err := s.lines()
+	if err != nil {
+		return err
+	}
+	kept := [][]byte{}
+	for i, l := range lines {
+		if i < len(lines)-s.maxRecords {
+			continue
+		}
+		r := Record{}
+		if err := json.Unmarshal(l, &r); err == nil && r.ID == 0 {
+			r.ID = i + 1
+			if b, err := json.Marshal(r); err == nil {
+				l = append(b, '\n')
+			}
+		}
+		kept = append(kept, l)
+	}
+	// replace the file atomically so that an interrupted trim does not
+	// lose the history
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bytes.Join(kept, nil), 0o644); err != nil {
+		return errors.Wrap(err, "cannot trim the history")
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errors.Wrap(err, "cannot trim the history")
+	}
+	return nil
+}
+
+// lines provides the lines of the file, each with its line feed.
+func (s *Store) lines() ([][]byte, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open the history")
+	}
+	defer f.Close()
+
+	lines := [][]byte{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*maxOutput)
+	for sc.Scan() {
+		lines = append(lines, append(append([]byte{}, sc.Bytes()...), '\n'))
+	}
+	return lines, errors.Wrap(sc.Err(), "cannot read the history")
+}
+
+// decode provides the records of the lines, skipping the lines which cannot
+// be decoded. Records written before the IDs were stored are numbered by
+// their line.
+func decode(lines [][]byte) []Record {
+	records := []Record{}
+	for i, l := range lines {
+		r := Record{}
+		if err := json.Unmarshal(l, &r); err != nil {
+			continue
+		}
+		if r.ID == 0 {
+			r.ID = i + 1
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// Load provides all the records, oldest first. Lines which cannot be
+// decoded are skipped.
+func (s *Store) Load() ([]Record, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	lines, err := s.lines()
+	if err != nil {
+		return nil, err
+	}
+	return decode(lines), nil
+}
+
+// Filter selects records. Zero values match everything.
+type Filter struct {
+	FailedOnly bool
+	Rule       string
+	// File matches records triggered by a change of a path containing it.
+	File  string
+	Since time.Time
+}
+
+// Match reports whether the record is selected by the filter.
+func (f Filter) Match(r Record) bool {
+	if f.FailedOnly && !r.Failed() {
+		return false
+	}
+	if f.Rule != "" && r.Rule != f.Rule {
+		return false
+	}
+	if !f.Since.IsZero() && r.Started.Before(f.Since) {
+		return false
+	}
+	if f.File == "" {
+		return true
+	}
+	for _, t := range r.Triggers {
+		if strings.Contains(t.Path, f.File) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select provides the records matching the filter.
+func Select(records []Record, f Filter) []Record {
+	selected := []Record{}
+	for _, r := range records {
+		if f.Match(r) {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+// Stats summarises records.
+type Stats struct {
+	Runs            int
+	Failed          int
+	AverageDuration time.Duration
+}
+
+// Summarize provides the statistics of the records.
+func Summarize(records []Record) Stats {
+	st := Stats{Runs: len(records)}
+	var total time.Duration
+	for _, r := range records {
+		if r.Failed() {
+			st.Failed++
+		}
+		total += r.Duration()
+	}
+	if st.Runs > 0 {
+		st.AverageDuration = total / time.Duration(st.Runs)
+	}
+	return st
+}
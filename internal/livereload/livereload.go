@@ -0,0 +1,191 @@
+// Package livereload notifies connected browsers to reload after
+// a successful run of the command.
+package livereload
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+// Paths the Server handlers are expected to be mounted on.
+const (
+	ScriptPath = "/livereload.js"
+	SocketPath = "/livereload"
+)
+
+// Commands sent to the browsers.
+const (
+	CommandReload = "reload"
+	CommandCSS    = "css"
+)
+
+// Message is sent to the browsers as JSON.
+type Message struct {
+	Command string   `json:"command"`
+	Paths   []string `json:"paths,omitempty"`
+}
+
+// the number of messages queued for a browser, one falling further
+// behind is dropped
+const sendBuffer = 8
+
+// Server serves the live-reload script and WebSocket endpoint
+// and tells the browsers to reload when a run succeeds.
+type Server struct {
+	mux     *sync.Mutex
+	clients map[*client]struct{}
+	// changes detected since the last run started
+	pending []string
+	// changes which triggered the current run
+	running []string
+}
+
+// New is a constructor providing a new Server.
+func New() *Server {
+	return &Server{
+		mux:     &sync.Mutex{},
+		clients: map[*client]struct{}{},
+	}
+}
+
+// Handle collects the changed files and notifies the browsers
+// after a successful run.
+func (s *Server) Handle(e event.Event) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	switch e.Type {
+	case event.ChangeDetected:
+		s.pending = append(s.pending, e.Change.Path)
+	case event.RunStarted:
+		s.running, s.pending = s.pending, nil
+	case event.Readiness:
+		// in the restart mode the process keeps running,
+		// the browsers reload once it is ready
+		if e.Run.State == event.Ready {
+			s.broadcast(newMessage(s.running))
+		}
+		if e.Run.State != event.Starting {
+			s.running = nil
+		}
+	case event.RunFinished:
+		if e.Run.ExitCode == 0 && !e.Run.Restart {
+			s.broadcast(newMessage(s.running))
+		}
+		s.running = nil
+	}
+}
+
+// newMessage asks for a stylesheet swap when only .css files changed,
+// and for a full reload otherwise.
+func newMessage(changes []string) Message {
+	if len(changes) == 0 {
+		return Message{Command: CommandReload}
+	}
+	for _, c := range changes {
+		if filepath.Ext(c) != ".css" {
+			return Message{Command: CommandReload, Paths: changes}
+		}
+	}
+	return Message{Command: CommandCSS, Paths: changes}
+}
+
+// client is a connected browser, with the messages queued for it.
+type client struct {
+	conn *wsConn
+	send chan []byte
+}
+
+// writeMessages sends the queued messages until the client is dropped
+// or cannot be written to.
+func (c *client) writeMessages() {
+	defer c.conn.Close()
+	for b := range c.send {
+		if err := c.conn.writeFrame(opText, b); err != nil {
+			return
+		}
+	}
+}
+
+// broadcast queues the message for all clients without waiting for it to be
+// sent, dropping the ones too far behind. Must be called with the mutex held.
+func (s *Server) broadcast(m Message) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	for c := range s.clients {
+		select {
+		case c.send <- b:
+		default:
+			s.drop(c)
+		}
+	}
+}
+
+// drop forgets the client, which stops its writer. Must be called with
+// the mutex held.
+func (s *Server) drop(c *client) {
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		close(c.send)
+	}
+}
+
+// ServeHTTP serves the script and the WebSocket endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case ScriptPath:
+		w.Header().Set("Content-Type", "application/javascript")
+		w.Header().Set("Cache-Control", "no-cache")
+		//nolint:errcheck
+		w.Write([]byte(script))
+	case SocketPath:
+		s.serveSocket(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrade(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c := &client{conn: conn, send: make(chan []byte, sendBuffer)}
+	s.mux.Lock()
+	s.clients[c] = struct{}{}
+	s.mux.Unlock()
+	go c.writeMessages()
+
+	defer func() {
+		s.mux.Lock()
+		s.drop(c)
+		s.mux.Unlock()
+		conn.Close()
+	}()
+
+	// the browsers do not send anything meaningful, reading only
+	// answers pings and detects closed connections
+	for {
+		op, payload, err := conn.readFrame()
+		if err != nil {
+			return
+		}
+		switch op {
+		case opPing:
+			//nolint:errcheck
+			conn.writeFrame(opPong, payload)
+		case opClose:
+			//nolint:errcheck
+			conn.writeFrame(opClose, nil)
+			return
+		}
+	}
+}
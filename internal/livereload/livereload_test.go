@@ -0,0 +1,206 @@
+package livereload_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/livereload"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		changes []string
+		want    livereload.Message
+	}{
+		{
+			name:    "css only",
+			changes: []string{"static/a.css", "static/b.css"},
+			want:    livereload.Message{Command: livereload.CommandCSS, Paths: []string{"static/a.css", "static/b.css"}},
+		},
+		{
+			name:    "mixed",
+			changes: []string{"static/a.css", "main.go"},
+			want:    livereload.Message{Command: livereload.CommandReload, Paths: []string{"static/a.css", "main.go"}},
+		},
+		{
+			name: "manual trigger",
+			want: livereload.Message{Command: livereload.CommandReload},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := livereload.New()
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+
+			r := dial(t, srv.URL)
+
+			// a failed run does not reload
+			s.Handle(event.Event{Type: event.ChangeDetected, Change: &event.Change{Path: "x.go"}})
+			s.Handle(event.Event{Type: event.RunStarted, Run: &event.Run{}})
+			s.Handle(event.Event{Type: event.RunFinished, Run: &event.Run{ExitCode: 1}})
+
+			for _, c := range tt.changes {
+				s.Handle(event.Event{Type: event.ChangeDetected, Change: &event.Change{Path: c}})
+			}
+			s.Handle(event.Event{Type: event.RunStarted, Run: &event.Run{}})
+			s.Handle(event.Event{Type: event.RunFinished, Run: &event.Run{ExitCode: 0}})
+
+			got := livereload.Message{}
+			if err := json.Unmarshal(readTextFrame(t, r), &got); err != nil {
+				t.Fatalf("invalid message: %s", err)
+			}
+			if got.Command != tt.want.Command || strings.Join(got.Paths, ",") != strings.Join(tt.want.Paths, ",") {
+				t.Errorf("Server sent %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_Script(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	livereload.New().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, livereload.ScriptPath, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/livereload") {
+		t.Errorf("Server script = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// dial performs the WebSocket handshake and waits until the client
+// is registered.
+func dial(t *testing.T, url string) *bufio.Reader {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := conn.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.WriteString(conn, "GET /livereload HTTP/1.1\r\nHost: test\r\n"+
+		"Connection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake failed: %s %v", resp.Status, resp.Header)
+	}
+	// the client is registered right after the handshake response is flushed
+	time.Sleep(50 * time.Millisecond)
+
+	return r
+}
+
+func readTextFrame(t *testing.T, r *bufio.Reader) []byte {
+	t.Helper()
+
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		t.Fatal(err)
+	}
+	if hdr[0] != 0x81 || hdr[1] == 126 {
+		t.Fatalf("unexpected frame header %x", hdr)
+	}
+	n := uint64(hdr[1])
+	if n == 127 {
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			t.Fatal(err)
+		}
+		n = binary.BigEndian.Uint64(ext)
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestServer_stalledClient(t *testing.T) {
+	t.Parallel()
+
+	s := livereload.New()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	// the client never reads, a message bigger than the socket buffers
+	// stalls the write to it
+	dial(t, srv.URL)
+	s.Handle(event.Event{Type: event.ChangeDetected, Change: &event.Change{Path: strings.Repeat("a", 16<<20)}})
+	s.Handle(event.Event{Type: event.RunStarted, Run: &event.Run{}})
+
+	done := make(chan struct{})
+	go func() {
+		s.Handle(event.Event{Type: event.RunFinished, Run: &event.Run{}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server.Handle() blocked on a stalled client")
+	}
+}
+
+func TestServer_slowClient(t *testing.T) {
+	t.Parallel()
+
+	s := livereload.New()
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	reload := func(path string) {
+		s.Handle(event.Event{Type: event.ChangeDetected, Change: &event.Change{Path: path}})
+		s.Handle(event.Event{Type: event.RunStarted, Run: &event.Run{}})
+		s.Handle(event.Event{Type: event.RunFinished, Run: &event.Run{}})
+	}
+
+	// the slow client never reads, the first message, bigger than the socket
+	// buffers, stalls the writes to it while the following ones pile up
+	r := dial(t, srv.URL)
+	slow := dial(t, srv.URL)
+	for i := 0; i < 12; i++ {
+		path := fmt.Sprintf("%d.go", i)
+		if i == 0 {
+			path = strings.Repeat("a", 16<<20)
+		}
+		reload(path)
+
+		got := livereload.Message{}
+		if err := json.Unmarshal(readTextFrame(t, r), &got); err != nil {
+			t.Fatalf("invalid message: %s", err)
+		}
+		if len(got.Paths) != 1 || got.Paths[0] != path {
+			t.Errorf("Server sent %.50v, want the path %.50s", got, path)
+		}
+	}
+	// the slow client is dropped
+	if _, err := io.Copy(ioutil.Discard, slow); err != nil {
+		t.Errorf("Server did not drop the slow client: %s", err)
+	}
+}
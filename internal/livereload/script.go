@@ -0,0 +1,37 @@
+package livereload
+
+// script is served to the browsers. It connects to the WebSocket endpoint
+// on the host it was loaded from and reloads the page, or only the
+// stylesheets, when told to. It reconnects when the connection drops.
+const script = `(function () {
+  var src = new URL(document.currentScript.src);
+  var proto = src.protocol === "https:" ? "wss://" : "ws://";
+  var url = proto + src.host + "/livereload";
+
+  function reloadCSS() {
+    var links = document.querySelectorAll("link[rel=stylesheet]");
+    for (var i = 0; i < links.length; i++) {
+      var href = new URL(links[i].href);
+      href.searchParams.set("livereload", Date.now());
+      links[i].href = href.toString();
+    }
+  }
+
+  function connect() {
+    var ws = new WebSocket(url);
+    ws.onmessage = function (m) {
+      var msg = JSON.parse(m.data);
+      if (msg.command === "css") {
+        reloadCSS();
+      } else {
+        location.reload();
+      }
+    };
+    ws.onclose = function () {
+      setTimeout(connect, 1000);
+    };
+  }
+
+  connect();
+})();
+`
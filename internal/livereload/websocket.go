@@ -0,0 +1,163 @@
+package livereload
+
+import (
+	"bufio"
+	"crypto/sha1" //nolint:gosec // required by the WebSocket handshake
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The livereload clients only need to receive short text messages, so
+// a minimal server side implementation of RFC 6455 is provided instead
+// of depending on a WebSocket library.
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// frame opcodes
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// maximum size of a frame accepted from a client
+const maxClientPayload = 1 << 16
+
+// writeTimeout limits the time a frame is being written
+const writeTimeout = time.Second
+
+type wsConn struct {
+	mux  *sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// upgrade performs the opening handshake and takes over the connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New("not a websocket handshake")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot hijack connection")
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID)) //nolint:gosec
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	// write errors are reported by Flush
+	//nolint:errcheck
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "cannot complete handshake")
+	}
+
+	return &wsConn{mux: &sync.Mutex{}, conn: conn, rw: rw}, nil
+}
+
+func headerContains(h http.Header, name, value string) bool {
+	for _, v := range strings.Split(h.Get(name), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFrame sends an unmasked final frame. A write not done within
+// writeTimeout fails, so that a stalled browser is given up on.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	hdr := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		hdr = append(hdr, byte(n))
+	case n <= 0xFFFF:
+		hdr = append(hdr, 126, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr = append(hdr, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+	// write errors are reported by Flush
+	//nolint:errcheck
+	c.rw.Write(hdr)
+	//nolint:errcheck
+	c.rw.Write(payload)
+	return c.rw.Flush()
+}
+
+// readFrame reads a single frame sent by the client, unmasking its payload.
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(c.rw, hdr); err != nil {
+		return 0, nil, err
+	}
+	op := hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	n := uint64(hdr[1] & 0x7F)
+
+	switch n {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext)
+	}
+	if n > maxClientPayload {
+		return 0, nil, errors.New("frame too large")
+	}
+
+	mask := make([]byte, 4)
+	if masked {
+		if _, err := io.ReadFull(c.rw, mask); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
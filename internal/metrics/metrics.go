@@ -0,0 +1,151 @@
+// Package metrics exposes the daemon lifecycle events as Prometheus metrics
+// in the text exposition format, without depending on the Prometheus client.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+const namespace = "gofileswatcher"
+
+// run states reported by the run state gauge
+const (
+	stateIdle    = "idle"
+	stateRunning = "running"
+)
+
+// Collector aggregates the daemon events into metrics
+// and serves them over HTTP.
+type Collector struct {
+	mux *sync.Mutex
+
+	backend      string
+	filesWatched int
+	runState     string
+	events       map[event.Kind]uint64
+	runs         map[string]uint64
+
+	scanDuration *histogram
+	runDuration  *histogram
+}
+
+// New is a constructor providing a new Collector. The backend is the name
+// of the mechanism used to detect changes, eg poll.
+func New(backend string) *Collector {
+	return &Collector{
+		mux:      &sync.Mutex{},
+		backend:  backend,
+		runState: stateIdle,
+		events:   map[event.Kind]uint64{},
+		runs:     map[string]uint64{},
+
+		scanDuration: newHistogram(namespace+"_scan_duration_seconds",
+			"Duration of walks through the watched files.",
+			[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}),
+		runDuration: newHistogram(namespace+"_run_duration_seconds",
+			"Duration of the command runs.",
+			[]float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120, 300}),
+	}
+}
+
+// Handle updates the metrics with the event.
+func (c *Collector) Handle(e event.Event) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	switch e.Type {
+	case event.ScanFinished:
+		c.filesWatched = e.Scan.Files
+		c.scanDuration.observe(e.Scan.Duration.Seconds())
+	case event.ChangeDetected:
+		c.events[e.Change.Kind]++
+	case event.RunStarted:
+		c.runState = stateRunning
+	case event.Readiness:
+		// in the restart mode the readiness decides the outcome of the run
+		c.runState = string(e.Run.State)
+		switch e.Run.State {
+		case event.Ready:
+			c.runs["success"]++
+		case event.Failed:
+			c.runs["failure"]++
+		}
+	case event.RunFinished:
+		c.runState = stateIdle
+		c.runDuration.observe(e.Run.Duration.Seconds())
+		if !e.Run.Restart {
+			c.runs[runResult(e.Run)]++
+		}
+	}
+}
+
+func runResult(r *event.Run) string {
+	if r.ExitCode == 0 {
+		return "success"
+	}
+	return "failure"
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text format to w.
+func (c *Collector) Write(w io.Writer) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.scanDuration.write(w)
+
+	writeHeader(w, "files_watched", "Number of files found by the last scan.", "gauge")
+	fmt.Fprintf(w, "%s_files_watched %d\n", namespace, c.filesWatched)
+
+	writeHeader(w, "events_total", "Detected file changes by kind.", "counter")
+	for _, k := range sortedKeys(c.events) {
+		fmt.Fprintf(w, "%s_events_total{kind=%q} %d\n", namespace, k, c.events[event.Kind(k)])
+	}
+
+	writeHeader(w, "runs_total", "Command runs by result.", "counter")
+	for _, res := range []string{"success", "failure"} {
+		fmt.Fprintf(w, "%s_runs_total{result=%q} %d\n", namespace, res, c.runs[res])
+	}
+
+	c.runDuration.write(w)
+
+	writeHeader(w, "run_state", "Current state of the command, 1 for the active state.", "gauge")
+	states := []string{stateIdle, stateRunning, string(event.Starting), string(event.Ready), string(event.Failed)}
+	for _, s := range states {
+		fmt.Fprintf(w, "%s_run_state{state=%q} %d\n", namespace, s, boolToInt(c.runState == s))
+	}
+
+	writeHeader(w, "backend_info", "Mechanism used to detect file changes.", "gauge")
+	fmt.Fprintf(w, "%s_backend_info{backend=%q} 1\n", namespace, c.backend)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, typ)
+}
+
+func sortedKeys(m map[event.Kind]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,61 @@
+package metrics_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/metrics"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestCollector_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	c := metrics.New("poll")
+	for _, e := range []event.Event{
+		{Type: event.ScanFinished, Scan: &event.Scan{Files: 5, Duration: 3 * time.Millisecond}},
+		{Type: event.ChangeDetected, Change: &event.Change{Path: "a.go", Kind: event.Modified}},
+		{Type: event.ChangeDetected, Change: &event.Change{Path: "b.go", Kind: event.Modified}},
+		{Type: event.RunStarted, Run: &event.Run{}},
+		{Type: event.RunFinished, Run: &event.Run{ExitCode: 2, Duration: 2 * time.Second}},
+		{Type: event.RunStarted, Run: &event.Run{}},
+	} {
+		c.Handle(e)
+	}
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET metrics error = %s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading metrics error = %s", err)
+	}
+
+	for _, want := range []string{
+		`gofileswatcher_files_watched 5`,
+		`gofileswatcher_scan_duration_seconds_bucket{le="0.005"} 1`,
+		`gofileswatcher_scan_duration_seconds_count 1`,
+		`gofileswatcher_events_total{kind="modified"} 2`,
+		`gofileswatcher_runs_total{result="success"} 0`,
+		`gofileswatcher_runs_total{result="failure"} 1`,
+		`gofileswatcher_run_duration_seconds_bucket{le="1"} 0`,
+		`gofileswatcher_run_duration_seconds_bucket{le="2.5"} 1`,
+		`gofileswatcher_run_duration_seconds_sum 2`,
+		`gofileswatcher_run_state{state="running"} 1`,
+		`gofileswatcher_run_state{state="idle"} 0`,
+		`gofileswatcher_backend_info{backend="poll"} 1`,
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("Collector.ServeHTTP() missing %q in\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package proxy provides a development reverse proxy in front of
+// an application restarted by the daemon. Requests arriving while the
+// application is restarting are held until it accepts connections again.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tamarakaufler/go-files-watcher/internal/livereload"
+)
+
+// how often connecting to the application is retried while holding a request
+const retryInterval = 100 * time.Millisecond
+
+// Proxy forwards requests to the application.
+type Proxy struct {
+	target      *url.URL
+	holdTimeout time.Duration
+	liveReload  http.Handler
+	rp          *httputil.ReverseProxy
+}
+
+// Option provides a way to customise the Proxy.
+type Option func(*Proxy)
+
+// New is a constructor providing a new Proxy forwarding to the target.
+func New(target *url.URL, ops ...Option) *Proxy {
+	p := &Proxy{
+		target:      target,
+		holdTimeout: 30 * time.Second,
+	}
+	for _, o := range ops {
+		o(p)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = p.dial
+
+	rp := httputil.NewSingleHostReverseProxy(target)
+	director := rp.Director
+	rp.Director = func(r *http.Request) {
+		director(r)
+		if p.liveReload != nil {
+			// compressed responses cannot have the script injected
+			r.Header.Del("Accept-Encoding")
+		}
+	}
+	rp.Transport = transport
+	rp.ModifyResponse = p.injectScript
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		http.Error(w, fmt.Sprintf("application is not available: %s", err), http.StatusBadGateway)
+	}
+	p.rp = rp
+
+	return p
+}
+
+// WithHoldTimeout allows to override how long requests are held
+// while the application is not accepting connections.
+func WithHoldTimeout(t time.Duration) Option {
+	return func(p *Proxy) {
+		p.holdTimeout = t
+	}
+}
+
+// WithLiveReload serves the live-reload endpoints by the provided handler
+// and injects the live-reload script into HTML responses.
+func WithLiveReload(h http.Handler) Option {
+	return func(p *Proxy) {
+		p.liveReload = h
+	}
+}
+
+// ServeHTTP forwards the request to the application.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.liveReload != nil &&
+		(r.URL.Path == livereload.ScriptPath || r.URL.Path == livereload.SocketPath) {
+		p.liveReload.ServeHTTP(w, r)
+		return
+	}
+	p.rp.ServeHTTP(w, r)
+}
+
+// dial connects to the application, retrying while the connection
+// is refused, eg because the application is being restarted.
+func (p *Proxy) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.holdTimeout)
+	defer cancel()
+
+	d := &net.Dialer{}
+	for {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(err, "cannot connect to %s", addr)
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// injectScript adds the live-reload script to uncompressed HTML responses.
+func (p *Proxy) injectScript(resp *http.Response) error {
+	if p.liveReload == nil ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") ||
+		resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "cannot read response")
+	}
+	body = inject(body)
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// inject inserts the script tag before the closing body tag,
+// or appends it when there is none.
+func inject(body []byte) []byte {
+	tag := []byte(`<script src="` + livereload.ScriptPath + `"></script>`)
+
+	i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+	if i < 0 {
+		return append(body, tag...)
+	}
+
+	out := make([]byte, 0, len(body)+len(tag))
+	out = append(out, body[:i]...)
+	out = append(out, tag...)
+	return append(out, body[i:]...)
+}
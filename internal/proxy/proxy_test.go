@@ -0,0 +1,151 @@
+package proxy_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/proxy"
+)
+
+func TestProxy_InjectsScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		liveReload  bool
+		want        string
+	}{
+		{
+			name:        "html with body",
+			contentType: "text/html; charset=utf-8",
+			body:        "<html><BODY>hi</BODY></html>",
+			liveReload:  true,
+			want:        `<html><BODY>hi<script src="/livereload.js"></script></BODY></html>`,
+		},
+		{
+			name:        "html fragment",
+			contentType: "text/html",
+			body:        "<p>hi</p>",
+			liveReload:  true,
+			want:        `<p>hi</p><script src="/livereload.js"></script>`,
+		},
+		{
+			name:        "not html",
+			contentType: "application/json",
+			body:        `{"a":"</body>"}`,
+			liveReload:  true,
+			want:        `{"a":"</body>"}`,
+		},
+		{
+			name:        "live-reload disabled",
+			contentType: "text/html",
+			body:        "<body></body>",
+			want:        "<body></body>",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer app.Close()
+
+			ops := []proxy.Option{}
+			if tt.liveReload {
+				ops = append(ops, proxy.WithLiveReload(http.NotFoundHandler()))
+			}
+			target, _ := url.Parse(app.URL)
+			srv := httptest.NewServer(proxy.New(target, ops...))
+			defer srv.Close()
+
+			got := get(t, srv.URL)
+			if got != tt.want {
+				t.Errorf("Proxy response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxy_HoldsWhileRestarting(t *testing.T) {
+	t.Parallel()
+
+	// reserve an address the application starts listening on later
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	target, _ := url.Parse("http://" + addr)
+	srv := httptest.NewServer(proxy.New(target, proxy.WithHoldTimeout(5*time.Second)))
+	defer srv.Close()
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		app := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "up")
+		}))
+		app.Listener.Close()
+		al, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		app.Listener = al
+		app.Start()
+		t.Cleanup(app.Close)
+	}()
+
+	if got := get(t, srv.URL); got != "up" {
+		t.Errorf("Proxy response = %q, want %q", got, "up")
+	}
+}
+
+func TestProxy_HoldTimeout(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := url.Parse("http://" + l.Addr().String())
+	l.Close()
+
+	srv := httptest.NewServer(proxy.New(target, proxy.WithHoldTimeout(200*time.Millisecond)))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Proxy status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
+
+func get(t *testing.T, url string) string {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
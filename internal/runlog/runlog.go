@@ -0,0 +1,201 @@
+// Package runlog captures the output of each run of the command into its own
+// file in a log directory, removing the oldest files when there are too many
+// or they take too much space.
+package runlog
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Latest is the name of the symlink pointing to the log of the latest run.
+const Latest = "latest.log"
+
+// prefix and suffix of the names of the run logs
+const (
+	prefix = "run-"
+	suffix = ".log"
+)
+
+// Dir keeps the logs of the runs.
+type Dir struct {
+	mux      *sync.Mutex
+	path     string
+	maxFiles int
+	maxSize  int64
+	now      func() time.Time
+}
+
+// Option provides a way to customise the Dir.
+type Option func(*Dir)
+
+// New is a constructor providing a Dir keeping the logs in the directory
+// at path, which is created if needed.
+func New(path string, ops ...Option) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, errors.Wrap(err, "cannot create the log directory")
+	}
+	d := &Dir{
+		mux:      &sync.Mutex{},
+		path:     path,
+		maxFiles: 20,
+		maxSize:  100 << 20,
+		now:      time.Now,
+	}
+	for _, o := range ops {
+		o(d)
+	}
+	return d, nil
+}
+
+// WithMaxFiles allows to override how many run logs are kept (20 by default).
+// Zero keeps all of them.
+func WithMaxFiles(n int) Option {
+	return func(d *Dir) {
+		d.maxFiles = n
+	}
+}
+
+// WithMaxSize allows to override the total size in bytes of the kept run logs
+// (100MiB by default). The log of the latest run is kept, cut at the limit
+// when it alone is bigger. Zero does not limit the size.
+func WithMaxSize(n int64) Option {
+	return func(d *Dir) {
+		d.maxSize = n
+	}
+}
+
+// Open creates the log of a new run of the rule and points the latest.log
+// symlink to it. Older logs are removed to keep within the limits, when
+// the run starts and again when its log is closed.
+func (d *Dir) Open(rule string) (io.WriteCloser, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	name := fmt.Sprintf("%s%s-%s%s", prefix, d.now().Format("20060102-150405.000000000"), safeName(rule), suffix)
+	f, err := os.OpenFile(filepath.Join(d.path, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create the run log")
+	}
+
+	// replace the symlink atomically, it is best effort as eg not all
+	// systems allow to create symlinks
+	tmp := filepath.Join(d.path, "."+Latest)
+	//nolint:errcheck
+	os.Remove(tmp)
+	if err := os.Symlink(name, tmp); err == nil {
+		//nolint:errcheck
+		os.Rename(tmp, filepath.Join(d.path, Latest))
+	}
+
+	if err := d.rotate(name); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &runLog{mux: &sync.Mutex{}, d: d, f: f, name: name}, nil
+}
+
+// note ends a run log cut at the size limit
+const note = "\n[the rest of the output is not logged, the log reached the size limit]\n"
+
+// runLog is the log of a run, which stops growing at the size limit, so that
+// a long running process does not fill the disk.
+type runLog struct {
+	mux     *sync.Mutex
+	d       *Dir
+	f       *os.File
+	name    string
+	written int64
+	cut     bool
+}
+
+// Write writes to the log up to the size limit, discarding the rest.
+func (l *runLog) Write(p []byte) (int, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.cut {
+		return len(p), nil
+	}
+	b := p
+	if max := l.d.maxSize; max > 0 && l.written+int64(len(b)) > max {
+		// leave room for the note
+		end := max - int64(len(note)) - l.written
+		if end < 0 {
+			end = 0
+		}
+		b = b[:end]
+		l.cut = true
+	}
+	n, err := l.f.Write(b)
+	l.written += int64(n)
+	if err != nil {
+		return n, err
+	}
+	if l.cut && l.written+int64(len(note)) <= l.d.maxSize {
+		if _, err := io.WriteString(l.f, note); err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the log and removes the oldest logs over the limits.
+func (l *runLog) Close() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.d.mux.Lock()
+	defer l.d.mux.Unlock()
+	return l.d.rotate(l.name)
+}
+
+// rotate removes the oldest run logs over the limits, keeping the current one.
+func (d *Dir) rotate(current string) error {
+	entries, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return errors.Wrap(err, "cannot list the log directory")
+	}
+
+	logs := []os.FileInfo{}
+	for _, e := range entries {
+		if e.Mode().IsRegular() && strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), suffix) {
+			logs = append(logs, e)
+		}
+	}
+	// the names start with the time, so the newest come first
+	sort.Slice(logs, func(i, j int) bool { return logs[i].Name() > logs[j].Name() })
+
+	var size int64
+	for i, l := range logs {
+		size += l.Size()
+		if l.Name() == current {
+			continue
+		}
+		if (d.maxFiles > 0 && i >= d.maxFiles) || (d.maxSize > 0 && size > d.maxSize) {
+			if err := os.Remove(filepath.Join(d.path, l.Name())); err != nil {
+				return errors.Wrap(err, "cannot remove an old run log")
+			}
+		}
+	}
+	return nil
+}
+
+// safeName makes the rule name usable in a file name.
+func safeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' || r == ':' {
+			return '_'
+		}
+		return r
+	}, s)
+}
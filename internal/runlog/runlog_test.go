@@ -0,0 +1,120 @@
+package runlog_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/runlog"
+)
+
+func TestDir_Open(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		ops       []runlog.Option
+		runs      int
+		size      int
+		wantFiles int
+		// whether the latest log is cut at the size limit
+		wantCut bool
+	}{
+		{name: "unlimited", ops: []runlog.Option{runlog.WithMaxFiles(0), runlog.WithMaxSize(0)}, runs: 5, wantFiles: 5},
+		{name: "max files", ops: []runlog.Option{runlog.WithMaxFiles(3)}, runs: 5, wantFiles: 3},
+		{name: "max size", ops: []runlog.Option{runlog.WithMaxSize(25)}, runs: 5, size: 10, wantFiles: 2},
+		{
+			name:      "latest over max size",
+			ops:       []runlog.Option{runlog.WithMaxSize(150)},
+			runs:      3,
+			size:      200,
+			wantFiles: 1,
+			wantCut:   true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := t.TempDir()
+			dir, err := runlog.New(path, tt.ops...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			last := ""
+			for i := 0; i < tt.runs; i++ {
+				w, err := dir.Open("my rule")
+				if err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				last = fmt.Sprintf("%0*d", tt.size, i)
+				fmt.Fprint(w, last)
+				w.Close()
+			}
+
+			files, err := filepath.Glob(filepath.Join(path, "run-*-my_rule.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("Open() kept %d files, want %d", len(files), tt.wantFiles)
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(path, runlog.Latest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCut {
+				logged := strings.SplitN(string(b), "\n", 2)[0]
+				if len(b) > 150 || !strings.HasPrefix(last, logged) || !strings.Contains(string(b), "size limit") {
+					t.Errorf("%s got %q, want the start of %q cut at 150 bytes", runlog.Latest, b, last)
+				}
+			} else if string(b) != last {
+				t.Errorf("%s got %q, want %q", runlog.Latest, b, last)
+			}
+			if fi, err := os.Lstat(filepath.Join(path, runlog.Latest)); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("%s is not a symlink", runlog.Latest)
+			}
+			if strings.Contains(files[len(files)-1], " ") {
+				t.Errorf("Open() created %s", files[len(files)-1])
+			}
+		})
+	}
+}
+
+func TestDir_Open_longRunning(t *testing.T) {
+	t.Parallel()
+
+	path := t.TempDir()
+	dir, err := runlog.New(path, runlog.WithMaxSize(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := dir.Open("server")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	// a process in the restart mode keeps writing to the same log
+	line := strings.Repeat("x", 49) + "\n"
+	for i := 0; i < 100; i++ {
+		if n, err := fmt.Fprint(w, line); n != len(line) || err != nil {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(path, runlog.Latest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) > 1000 || !strings.HasPrefix(string(b), line) {
+		t.Errorf("%s got %d bytes, want the start of the output within 1000 bytes", runlog.Latest, len(b))
+	}
+}
@@ -0,0 +1,23 @@
+// Package terminal provides the terminal handling needed
+// for the interactive keyboard controls.
+package terminal
+
+import (
+	"errors"
+)
+
+// ErrNotSupported is returned on platforms where the terminal
+// mode cannot be changed.
+var ErrNotSupported = errors.New("terminal mode cannot be changed on this platform")
+
+// ClearScreen clears the terminal and moves the cursor to the top left corner.
+const ClearScreen = "\033[H\033[2J"
+
+// Escape sequences used by the full screen dashboard.
+const (
+	EnterAltScreen = "\033[?1049h\033[?25l"
+	ExitAltScreen  = "\033[?25h\033[?1049l"
+	CursorHome     = "\033[H"
+	ClearLine      = "\033[K"
+	ClearToEnd     = "\033[J"
+)
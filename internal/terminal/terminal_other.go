@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package terminal
+
+// IsTerminal reports whether the file descriptor is a terminal.
+// Terminals are not detected on this platform.
+func IsTerminal(fd uintptr) bool {
+	return false
+}
+
+// MakeCbreak is not supported on this platform.
+func MakeCbreak(fd uintptr) (func() error, error) {
+	return nil, ErrNotSupported
+}
+
+// Size is not supported on this platform.
+func Size(fd uintptr) (int, int, error) {
+	return 0, 0, ErrNotSupported
+}
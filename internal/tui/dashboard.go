@@ -0,0 +1,155 @@
+// Package tui provides a full screen terminal dashboard of the daemon.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/terminal"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+const (
+	maxChanges = 10
+	maxRuns    = 10
+	refresh    = 100 * time.Millisecond
+)
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Source provides the daemon state shown by the dashboard.
+type Source interface {
+	Status() daemon.Status
+	LastLog() string
+}
+
+// SizeFunc provides the width and height of the screen.
+type SizeFunc func() (int, int)
+
+type change struct {
+	at   time.Time
+	path string
+	kind event.Kind
+}
+
+type run struct {
+	number   int
+	started  time.Time
+	duration time.Duration
+	ok       bool
+	args     []string
+}
+
+// Dashboard collects the events and redraws the screen.
+type Dashboard struct {
+	out  io.Writer
+	size SizeFunc
+
+	mux        *sync.Mutex
+	changes    []change
+	runs       []run
+	runNumber  int
+	runStarted time.Time
+	state      string
+	scroll     int
+	frame      int
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// New is a constructor providing a new Dashboard drawing to out.
+func New(out io.Writer, size SizeFunc) *Dashboard {
+	return &Dashboard{
+		out:    out,
+		size:   size,
+		mux:    &sync.Mutex{},
+		state:  "idle",
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Handle updates the dashboard with the event.
+func (db *Dashboard) Handle(e event.Event) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	switch e.Type {
+	case event.ChangeDetected:
+		db.changes = append([]change{{at: e.Time, path: e.Change.Path, kind: e.Change.Kind}}, db.changes...)
+		if len(db.changes) > maxChanges {
+			db.changes = db.changes[:maxChanges]
+		}
+	case event.RunStarted:
+		db.runNumber++
+		db.runStarted = e.Time
+		db.state = "running"
+		db.scroll = 0
+	case event.Readiness:
+		db.state = string(e.Run.State)
+		if e.Run.State != event.Starting {
+			db.addRun(e.Run.State == event.Ready, e.Time.Sub(db.runStarted), e.Run.Args)
+		}
+	case event.RunFinished:
+		db.state = "idle"
+		if !e.Run.Restart {
+			db.addRun(e.Run.ExitCode == 0, e.Run.Duration, e.Run.Args)
+		}
+	}
+}
+
+func (db *Dashboard) addRun(ok bool, d time.Duration, args []string) {
+	db.runs = append([]run{{
+		number:   db.runNumber,
+		started:  db.runStarted,
+		duration: d,
+		ok:       ok,
+		args:     args,
+	}}, db.runs...)
+	if len(db.runs) > maxRuns {
+		db.runs = db.runs[:maxRuns]
+	}
+}
+
+// Scroll moves the output pane by the number of lines, positive
+// values scroll towards older output.
+func (db *Dashboard) Scroll(lines int) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.scroll += lines
+	if db.scroll < 0 {
+		db.scroll = 0
+	}
+}
+
+// Start switches to the alternate screen and redraws it regularly
+// with the state of the source until Stop is called.
+func (db *Dashboard) Start(src Source) {
+	fmt.Fprint(db.out, terminal.EnterAltScreen)
+	go func() {
+		defer close(db.doneCh)
+		tick := time.NewTicker(refresh)
+		defer tick.Stop()
+		for {
+			w, h := db.size()
+			fmt.Fprint(db.out, terminal.CursorHome+db.Render(src, w, h)+terminal.ClearToEnd)
+			select {
+			case <-tick.C:
+			case <-db.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing and restores the screen.
+func (db *Dashboard) Stop() {
+	close(db.stopCh)
+	<-db.doneCh
+	fmt.Fprint(db.out, terminal.ExitAltScreen)
+}
@@ -0,0 +1,80 @@
+package tui_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/tui"
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+type fakeSource struct {
+	log       string
+	basePaths []string
+}
+
+func (f fakeSource) Status() daemon.Status {
+	return daemon.Status{
+		Rule: "app", BasePath: "src", BasePaths: f.basePaths, Extension: ".go", FilesWatched: 42, Paused: true,
+	}
+}
+
+func (f fakeSource) LastLog() string { return f.log }
+
+func TestDashboard_Render(t *testing.T) {
+	t.Parallel()
+
+	log := []string{}
+	for i := 1; i <= 30; i++ {
+		log = append(log, fmt.Sprintf("output line %d", i))
+	}
+	src := fakeSource{log: strings.Join(log, "\n")}
+	db := tui.New(ioutil.Discard, nil)
+
+	now := time.Now()
+	for _, e := range []event.Event{
+		{Type: event.ChangeDetected, Time: now, Change: &event.Change{Path: "src/main.go", Kind: event.Modified}},
+		{Type: event.RunStarted, Time: now, Run: &event.Run{Args: []string{"go", "build"}}},
+		{Type: event.RunFinished, Time: now, Run: &event.Run{Args: []string{"go", "build"}, ExitCode: 1}},
+		{Type: event.RunStarted, Time: now, Run: &event.Run{Args: []string{"go", "build"}}},
+	} {
+		db.Handle(e)
+	}
+
+	screen := db.Render(src, 80, 20)
+	lines := strings.Split(screen, "\n")
+	if len(lines) != 20 {
+		t.Errorf("Dashboard.Render() = %d lines, want 20", len(lines))
+	}
+	for _, want := range []string{
+		"rule: app", "root: src", "files tracked: 42", "running", "[paused]",
+		"modified src/main.go", "#1   FAIL", "output line 30",
+	} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Dashboard.Render() missing %q in\n%s", want, screen)
+		}
+	}
+	if strings.Contains(screen, "output line 1\x1b") {
+		t.Errorf("Dashboard.Render() shows the start of the output, want its end")
+	}
+
+	db.Scroll(100)
+	screen = db.Render(src, 80, 20)
+	if !strings.Contains(screen, "output line 1\x1b") || strings.Contains(screen, "output line 30") {
+		t.Errorf("Dashboard.Render() after scrolling up =\n%s", screen)
+	}
+}
+
+func TestDashboard_Render_basePaths(t *testing.T) {
+	t.Parallel()
+
+	src := fakeSource{basePaths: []string{"web", "../lib"}}
+	screen := tui.New(ioutil.Discard, nil).Render(src, 120, 20)
+	if want := "roots: src, web, ../lib"; !strings.Contains(screen, want) {
+		t.Errorf("Dashboard.Render() missing %q in\n%s", want, screen)
+	}
+}
@@ -0,0 +1,125 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tamarakaufler/go-files-watcher/internal/daemon"
+	"github.com/tamarakaufler/go-files-watcher/internal/terminal"
+)
+
+const keysLine = "r re-run  p pause/resume  j/k scroll output  q quit"
+
+// Render provides the content of the screen of the given size.
+func (db *Dashboard) Render(src Source, width, height int) string {
+	st := src.Status()
+	log := strings.ReplaceAll(strings.TrimRight(src.LastLog(), "\n"), "\t", "    ")
+	logLines := strings.Split(log, "\n")
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	db.frame++
+
+	lines := []string{
+		fmt.Sprintf("go-files-watcher  rule: %s  %s  extension: %s  excluded: %s",
+			st.Rule, rootsLabel(st), st.Extension, strings.Join(st.Excluded, ", ")),
+		fmt.Sprintf("files tracked: %d   state: %s%s", st.FilesWatched, db.stateLine(), pausedLabel(st.Paused)),
+		section("recent changes", width),
+	}
+	for _, c := range db.changes {
+		lines = append(lines, fmt.Sprintf(" %s %-8s %s", c.at.Format("15:04:05"), c.kind, c.path))
+	}
+	lines = append(lines, section("runs", width))
+	for _, r := range db.runs {
+		lines = append(lines, fmt.Sprintf(" #%-3d %s %s %8s  %s",
+			r.number, passLabel(r.ok), r.started.Format("15:04:05"),
+			r.duration.Round(time.Millisecond), strings.Join(r.args, " ")))
+	}
+	lines = append(lines, section("last run output", width))
+
+	// the output pane takes the remaining space, less the keys line
+	paneHeight := height - len(lines) - 1
+	lines = append(lines, db.pane(logLines, paneHeight)...)
+	lines = append(lines, keysLine)
+
+	for i := range lines {
+		lines[i] = truncate(lines[i], width) + terminal.ClearLine
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rootsLabel provides the watched base paths.
+func rootsLabel(st daemon.Status) string {
+	if len(st.BasePaths) == 0 {
+		return "root: " + st.BasePath
+	}
+	return "roots: " + strings.Join(append([]string{st.BasePath}, st.BasePaths...), ", ")
+}
+
+// pane provides the visible part of the output, scrolled from its end.
+func (db *Dashboard) pane(lines []string, height int) []string {
+	if height <= 0 {
+		return nil
+	}
+	maxScroll := len(lines) - height
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	if db.scroll > maxScroll {
+		db.scroll = maxScroll
+	}
+	end := len(lines) - db.scroll
+	start := end - height
+	if start < 0 {
+		start = 0
+	}
+
+	visible := make([]string, 0, height)
+	visible = append(visible, lines[start:end]...)
+	for len(visible) < height {
+		visible = append(visible, "")
+	}
+	return visible
+}
+
+func (db *Dashboard) stateLine() string {
+	switch db.state {
+	case "running", "starting":
+		return fmt.Sprintf("%s %s %s", spinner[db.frame%len(spinner)], db.state,
+			time.Since(db.runStarted).Round(100*time.Millisecond))
+	default:
+		return db.state
+	}
+}
+
+func pausedLabel(paused bool) string {
+	if paused {
+		return "   [paused]"
+	}
+	return ""
+}
+
+func passLabel(ok bool) string {
+	if ok {
+		return "pass"
+	}
+	return "FAIL"
+}
+
+func section(title string, width int) string {
+	s := "── " + title + " "
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		s += strings.Repeat("─", n)
+	}
+	return s
+}
+
+// truncate shortens the line to the width in runes.
+func truncate(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
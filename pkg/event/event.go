@@ -0,0 +1,140 @@
+// Package event describes the lifecycle events emitted by the watcher daemon.
+//
+// The types in this package are the documented schema of the machine-readable
+// output (--output=json), where every event is written as one JSON object per
+// line (NDJSON). Consumers should check the Version field and ignore fields
+// they do not know about.
+package event
+
+import (
+	"time"
+)
+
+// SchemaVersion is the version of the event schema. It is increased whenever
+// a field is removed or its meaning changes. Adding new fields or event types
+// does not change the version.
+const SchemaVersion = 1
+
+// Type identifies the lifecycle event.
+type Type string
+
+// Lifecycle event types.
+const (
+	// ScanStarted is emitted when a walk through the watched files begins.
+	ScanStarted Type = "scan_started"
+	// ScanFinished is emitted when the walk finishes. Carries Scan.
+	ScanFinished Type = "scan_finished"
+	// ChangeDetected is emitted for a changed file. Carries Change.
+	ChangeDetected Type = "change_detected"
+	// RunStarted is emitted when the command is started. Carries Run.
+	RunStarted Type = "run_started"
+	// RunFinished is emitted when the command exits. Carries Run.
+	RunFinished Type = "run_finished"
+	// Readiness is emitted in the restart mode when the state of the
+	// started process changes. Carries Run with State.
+	Readiness Type = "readiness"
+	// Error is emitted when the daemon encounters an error. Carries Error.
+	Error Type = "error"
+)
+
+// Kind describes what happened to a file.
+type Kind string
+
+// Change kinds.
+const (
+	Created  Kind = "created"
+	Modified Kind = "modified"
+	Deleted  Kind = "deleted"
+	// Renamed is a file moved from OldPath to Path.
+	Renamed Kind = "renamed"
+	// AttributesChanged is a file with the same content and a changed mode,
+	// owner or extended attributes. Only reported when tracked.
+	AttributesChanged Kind = "attributes_changed"
+
+	// Directory changes. A directory is not modified by changes of its content.
+	DirCreated Kind = "dir_created"
+	DirDeleted Kind = "dir_deleted"
+	// DirRenamed is a directory moved from OldPath to Path.
+	DirRenamed Kind = "dir_renamed"
+)
+
+// State is the readiness of a process started in the restart mode.
+type State string
+
+// Readiness states. A failed process counts as a failed run.
+const (
+	Starting State = "starting"
+	Ready    State = "ready"
+	Failed   State = "failed"
+)
+
+// Event is a single lifecycle event. Only the payload matching the Type
+// is set, the others are omitted from the JSON output.
+type Event struct {
+	Version int `json:"version"`
+	// Seq increases by one with every event emitted by the daemon,
+	// starting at 1. Can be used to resume a stream after reconnecting.
+	Seq  uint64    `json:"seq"`
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	// Rule is the name of the daemon configuration emitting the event.
+	Rule string `json:"rule"`
+
+	Scan   *Scan   `json:"scan,omitempty"`
+	Change *Change `json:"change,omitempty"`
+	Run    *Run    `json:"run,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Scan describes a walk through the watched files.
+type Scan struct {
+	BasePath string `json:"base_path"`
+	// Roots are all the watched base paths, when there are more.
+	Roots []string `json:"roots,omitempty"`
+	// Files is the number of files found. Zero for ScanStarted.
+	Files int `json:"files"`
+	// Duration of the walk. Zero for ScanStarted.
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Change describes a detected file change.
+type Change struct {
+	Path string `json:"path"`
+	Kind Kind   `json:"kind"`
+	// OldPath is set for Renamed.
+	OldPath string `json:"old_path,omitempty"`
+	// Root is the watched base path of the file.
+	Root string `json:"root,omitempty"`
+}
+
+// Run describes a run of the command.
+type Run struct {
+	Args []string `json:"args"`
+	// ExitCode is set for RunFinished. It is -1 when the command
+	// could not be started or was killed by a signal.
+	ExitCode int `json:"exit_code"`
+	// Duration is set for RunFinished.
+	Duration time.Duration `json:"duration_ns"`
+	// Restart is set when the command is a long running process,
+	// which is restarted when a change is detected.
+	Restart bool `json:"restart,omitempty"`
+	// Stopped is set for RunFinished when the process was stopped
+	// by the daemon, eg to be restarted.
+	Stopped bool `json:"stopped,omitempty"`
+	// State is set for Readiness.
+	State State `json:"state,omitempty"`
+}
+
+// Handler receives events emitted by the daemon. Handle is called
+// synchronously, so implementations should not block.
+type Handler interface {
+	Handle(e Event)
+}
+
+// HandlerFunc allows to use an ordinary function as a Handler.
+type HandlerFunc func(e Event)
+
+// Handle calls f(e).
+func (f HandlerFunc) Handle(e Event) {
+	f(e)
+}
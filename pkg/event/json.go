@@ -0,0 +1,32 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSONWriter writes events as newline delimited JSON.
+type JSONWriter struct {
+	mux *sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONWriter is a constructor providing a Handler writing one JSON object
+// per line to w.
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{
+		mux: &sync.Mutex{},
+		enc: json.NewEncoder(w),
+	}
+}
+
+// Handle writes the event. Encoding errors are dropped as there is nowhere
+// to report them to.
+func (j *JSONWriter) Handle(e Event) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	//nolint:errcheck
+	j.enc.Encode(e)
+}
@@ -0,0 +1,45 @@
+package event_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tamarakaufler/go-files-watcher/pkg/event"
+)
+
+func TestJSONWriter_Handle(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	w := event.NewJSONWriter(buf)
+
+	w.Handle(event.Event{
+		Version: event.SchemaVersion,
+		Type:    event.ChangeDetected,
+		Change:  &event.Change{Path: "a/b.go", Kind: event.Modified},
+	})
+	w.Handle(event.Event{
+		Version: event.SchemaVersion,
+		Type:    event.RunFinished,
+		Run:     &event.Run{Args: []string{"go", "test"}, ExitCode: 1, Duration: time.Second},
+	})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("JSONWriter.Handle() wrote %d lines, want 2", len(lines))
+	}
+
+	var got event.Event
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("JSONWriter.Handle() wrote invalid JSON: %s", err)
+	}
+	if got.Type != event.RunFinished || got.Run == nil || got.Run.ExitCode != 1 || got.Change != nil {
+		t.Errorf("JSONWriter.Handle() = %+v, unexpected content", got)
+	}
+	if !strings.Contains(lines[0], `"kind":"modified"`) {
+		t.Errorf("JSONWriter.Handle() = %s, want change kind", lines[0])
+	}
+}